@@ -18,167 +18,415 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	bolt "github.com/johnnadratowski/golang-neo4j-bolt-driver"
-	"github.com/kkirsche/trace2neo/cypherBuilder"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/progress"
 	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/kkirsche/trace2neo/traceroute"
 	"github.com/spf13/cobra"
 )
 
 var (
 	successfulResolutions,
 	failedResolutions []string
-	write                    bool
-	err                      error
-	conn                     bolt.Conn
-	username, password, host string
-	port                     int
+	write            bool
+	assetsOutput     string
+	concurrency      int
+	qps              float64
+	dnsServers       []string
+	dnsTCP           bool
+	dnsTimeout       time.Duration
+	dnsRetries       int
+	fcrdns           bool
+	maxAddresses     uint64
+	stateFile        string
+	resume           bool
+	reportFile       string
+	failOnErrors     bool
+	progressMode     string
+	progressInterval time.Duration
+	probes           []string
+	probePorts       []int
+	probeTimeout     time.Duration
+	err              error
 )
 
+// checkpointInterval is how often a sweep flushes its writes and saves its
+// state
+const checkpointInterval = 10 * time.Second
+
 // assetsCmd represents the assets command
 var assetsCmd = &cobra.Command{
 	Use:   "assets",
 	Short: "Resolves CIDR blocks to Neo4j Nodes if in DNS",
-	Long: `Resolves a CIDR block or a list of CIDR blocks from DNS, and then builds
-a cypher query for use with Neo4j. The tool will output the assets.cypher file to
-the current working directory (./assets.cypher).
+	Long: `Resolves a CIDR block or a list of CIDR blocks from DNS, and then writes
+the assets to Neo4j. With --write, or --output, a cypher-shell script is written
+instead, to ./assets.cypher unless --output is given. An existing script is
+replaced.
+
+Targets may also be ranges such as 10.0.0.1-10.0.0.50, addresses or hostnames,
+and may be read from --targets-file. Every address is resolved once, however
+many targets hold it, and anything matching --exclude or --exclude-file is
+never resolved.
+
+Progress is saved to --state-file as the sweep goes, and on SIGINT or SIGTERM
+the lookups in flight are finished and written first. Run the same command
+again with --resume to carry on where it stopped.
 
 trace2neo assets <cidr>
 
 trace2neo assets <cidr>,<cidr>,<cidr>
 
 trace2neo assets <cidr>, <cidr>, <cidr>
+
+trace2neo assets --targets-file scope.txt --exclude-file never.txt
+
+trace2neo assets --resume --targets-file scope.txt
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		// args should be an array of CIDR notation addresses
-		if !write {
-			driver := bolt.NewDriver()
-			conn, err = driver.OpenNeo(fmt.Sprintf("bolt://%s:%s@%s:%d", username, password, host, port))
-			if err != nil {
-				logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
-				return
-			}
-			defer conn.Close()
+		if code := runAssets(cmd, args); code != 0 {
+			os.Exit(code)
 		}
+	},
+}
 
-		startTime := time.Now().UTC()
-		logrus.Infof("Beginning IP resolution at %s UTC", startTime.String())
-		for _, cidr := range args {
-			ip, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
-			if err != nil {
-				logrus.WithError(err).Errorf("Failed to parse %s as CIDR block. Skipping...", cidr)
-				continue
-			}
+// runAssets sweeps the targets, returning the exit code of the command
+func runAssets(cmd *cobra.Command, args []string) (code int) {
+	if verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	if resume && csvDir != "" {
+		logrus.Errorln("--resume can't be used with --csv-dir, as the CSV files are only written once the sweep ends.")
+		return exitFailed
+	}
 
-			var ips []string
-			for ipaddr := ip.Mask(ipnet.Mask); ipnet.Contains(ipaddr); inc(ipaddr) {
-				ips = append(ips, ipaddr.String())
+	targets, subnets, err := loadTargets(args)
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to load targets.")
+		return exitFailed
+	}
+
+	state := &trace2neolib.SweepState{}
+	if resume {
+		state, err = trace2neolib.LoadSweepState(stateFile)
+		switch {
+		case os.IsNotExist(err):
+			logrus.Warnf("No sweep to resume in %s. Starting from the beginning.", stateFile)
+			state = &trace2neolib.SweepState{}
+		case err != nil:
+			logrus.WithError(err).Errorf("Failed to read %s", stateFile)
+			return exitFailed
+		default:
+			logrus.Infof("Resuming sweep, skipping %d addresses already resolved.", state.Completed.Len())
+			for _, r := range state.Completed.Ranges() {
+				targets.Remove(r)
 			}
-			availableIPs := ips[1 : len(ips)-1]
+		}
+	}
 
-			t, err := cypherBuilder.GetAssetTemplate()
-			if err != nil {
-				logrus.WithError(err).Errorln("Failed to get asset template. Exiting")
-				return
+	ipv6 := false
+	for _, r := range targets.Ranges() {
+		ipv6 = ipv6 || r.First.To4() == nil
+	}
+	prober, err := newProber(ipv6)
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to set up liveness probing")
+		return exitFailed
+	}
+
+	opened, ok := openAssetsSink(cmd)
+	if !ok {
+		return exitFailed
+	}
+	sink := graphSink.NewCountingSink(opened)
+
+	sweeper := &trace2neolib.Sweeper{Concurrency: concurrency, QPS: qps, ConfirmForward: fcrdns, Prober: prober}
+	for _, server := range dnsServers {
+		resolver := trace2neolib.NewDNSResolver(server)
+		resolver.TCP = dnsTCP
+		resolver.Timeout = dnsTimeout
+		resolver.Retries = dnsRetries
+		sweeper.Resolvers = append(sweeper.Resolvers, resolver)
+	}
+
+	ranges := capRanges(targets.Ranges(), maxAddresses)
+
+	// stop is closed to end the sweep early if the assets can't be written,
+	// or if we are told to
+	addrs, stop := make(chan string), make(chan struct{})
+	var stopOnce sync.Once
+	halt := func() { stopOnce.Do(func() { close(stop) }) }
+
+	interrupted := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		logrus.Warnln("Interrupted. Finishing the lookups in flight...")
+		close(interrupted)
+		halt()
+	}()
+
+	var lenIPs int
+	for _, r := range ranges {
+		lenIPs += int(r.Len())
+	}
+
+	summary := newSweepSummary(uint64(lenIPs))
+	defer func() {
+		if err := sink.Close(); err != nil {
+			logrus.WithError(err).Errorln("Failed to write to the graph.")
+			summary.Status = sweepFailed
+		}
+		summary.finish(sink)
+		summary.log()
+		if reportFile != "" {
+			if err := summary.writeReport(reportFile); err != nil {
+				logrus.WithError(err).Errorf("Failed to write report to %s", reportFile)
+				summary.Status = sweepFailed
 			}
+		}
+		code = summary.exitCode(failOnErrors)
+	}()
 
-			lenIPs := len(availableIPs)
-			for i, availableIP := range availableIPs {
-				if i%250 == 0 {
-					logrus.Infof("Currently resolving %s (#%d of #%d)...", availableIP, i, lenIPs)
-				}
-				if i%1000 == 0 && i != 0 {
-					elapsedTime := time.Since(startTime)
-					remainingTime := time.Duration(((lenIPs - i) / 1000)) * elapsedTime
-					logrus.Infof("Elapsed Time: %s, Estimated Remaining Time: %s", elapsedTime.String(), remainingTime.String())
-				}
-				resolved, loopErr := trace2neolib.ResolveAddr(availableIP)
-				if loopErr != nil {
-					failedResolutions = append(failedResolutions, availableIP)
-				}
+	// the parts are the merged ranges being swept rather than the targets as
+	// given, so overlapping and adjacent blocks are shown as one range
+	tracker := progress.NewTracker()
+	for _, r := range ranges {
+		tracker.AddPart(r.String(), r.Len())
+	}
+	reporter, err := progress.Start(tracker, progressMode, progressInterval)
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to report progress.")
+		summary.Status = sweepFailed
+		return
+	}
+	defer reporter.Stop()
 
-				assets := trace2neolib.ResolvedAddrToAsset(resolved, availableIP, i)
-				if len(assets) > 0 {
-					for _, asset := range assets {
-						if !write {
-							stmt, innerLoopErr := conn.PrepareNeo("CREATE (n:Unknown {name: {name}, ip: {ip}})")
-							if innerLoopErr != nil {
-								logrus.WithError(err).Errorln("Failed to create statement.")
-								return
-							}
-							_, innerLoopErr = stmt.ExecNeo(map[string]interface{}{"shortName": asset.ShortName, "label": asset.Label, "name": asset.Name, "ip": asset.IPAddr})
-							if innerLoopErr != nil {
-								logrus.WithError(err).Errorln("Failed to execute create statement.")
-								return
-							}
-							stmt.Close()
-						}
-
-						if asset != nil {
-							assetString, innerLoopErr := cypherBuilder.BuildAsset(t, asset)
-							if innerLoopErr != nil {
-								logrus.WithError(innerLoopErr).Errorln("Failed to build asset %s", asset.IPAddr)
-								continue
-							}
-							successfulResolutions = append(successfulResolutions, assetString)
-						}
-					}
+	go func() {
+		defer close(addrs)
+		for _, r := range ranges {
+			more := true
+			r.Each(func(addr net.IP) bool {
+				select {
+				case addrs <- addr.String():
+				case <-stop:
+					more = false
 				}
+				return more
+			})
+			if !more {
+				return
 			}
 		}
+	}()
 
-		if write {
-			wd, err := os.Getwd()
-			if err != nil {
-				logrus.WithError(err).Errorln("Failed to get current working directory")
-			}
-			fp := wd + "/assets.cypher"
-			logrus.Infof("Writing cypher query to %s", fp)
-			cypherBuilder.WriteAssetsToFile(successfulResolutions, fp)
+	logrus.Infof("Beginning IP resolution of %d addresses at %s", lenIPs, summary.StartedAt.String())
+	var writeErr error
+	lastCheckpoint := time.Now()
+	sweeper.Sweep(addrs, func(result trace2neolib.SweepResult) {
+		tracker.Done(1)
+		if result.Err != nil {
+			failedResolutions = append(failedResolutions, result.Addr+" "+trace2neolib.FailureReason(result.Err))
+		}
+		if result.ProbeErr != nil {
+			logrus.WithError(result.ProbeErr).Debugf("Failed to probe %s", result.Addr)
+		}
+		if writeErr != nil {
+			return
 		}
 
-		if verbose {
-			logrus.Debugln("Successfully resolved:")
-			for _, success := range successfulResolutions {
-				logrus.Debugln(success)
+		assets := trace2neolib.ResolvedAddrToAsset(result.Resolved, result.Addr)
+		summary.record(result, assets)
+		subnet := trace2neolib.SubnetOf(subnets, net.ParseIP(result.Addr))
+		for _, asset := range assets {
+			if subnet != nil {
+				asset.Subnet = subnet.String()
+			}
+			asset.Liveness = result.Liveness
+		}
+		for _, asset := range assets {
+			if writeErr = graphSink.WriteAsset(sink, asset); writeErr != nil {
+				logrus.WithError(writeErr).Errorf("Failed to write asset %s", asset.IPAddr)
+				halt()
+				return
+			}
+			if asset.Name != "" {
+				successfulResolutions = append(successfulResolutions, asset.Name+" "+asset.IPAddr)
 			}
+			if asset.StalePTR() {
+				logrus.Debugf("Stale PTR: %s does not resolve back to %s", asset.Name, asset.IPAddr)
+			}
+		}
+		// addresses whose lookups failed are looked up again on --resume
+		if trace2neolib.Answered(result.Err) {
+			state.Complete(result.Addr)
 		}
 
-		if verbose {
-			logrus.Debugln("Failed to resolve:")
-			for _, failed := range failedResolutions {
-				logrus.Debugln(failed)
+		if time.Since(lastCheckpoint) >= checkpointInterval {
+			if writeErr = checkpoint(sink, state); writeErr != nil {
+				halt()
 			}
+			lastCheckpoint = time.Now()
 		}
-	},
+	})
+	if writeErr != nil {
+		logrus.Errorf("Sweep stopped. Run again with --resume to carry on from the last checkpoint in %s.", stateFile)
+		summary.Status = sweepFailed
+		return
+	}
+	select {
+	case <-interrupted:
+		if checkpoint(sink, state) == nil {
+			logrus.Warnf("Sweep interrupted. Run again with --resume to carry on from %s.", stateFile)
+		}
+		summary.Status = sweepInterrupted
+		return
+	default:
+	}
+
+	// keep the last checkpoint if the final writes fail, so the sweep can
+	// still be resumed
+	if err = sink.Flush(); err != nil {
+		logrus.WithError(err).Errorf("Failed to write to the graph. Run again with --resume to carry on from the last checkpoint in %s.", stateFile)
+		summary.Status = sweepFailed
+		return
+	}
+	if err = os.Remove(stateFile); err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).Warnf("Failed to remove %s", stateFile)
+	}
+
+	if verbose {
+		logrus.Debugln("Successfully resolved:")
+		for _, success := range successfulResolutions {
+			logrus.Debugln(success)
+		}
+	}
+
+	if verbose {
+		logrus.Debugln("Failed to resolve:")
+		for _, failed := range failedResolutions {
+			logrus.Debugln(failed)
+		}
+	}
+	return
 }
 
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
+// capRanges returns the ranges holding no more than max addresses, skipping
+// the rest
+func capRanges(ranges []*trace2neolib.AddrRange, max uint64) []*trace2neolib.AddrRange {
+	var capped []*trace2neolib.AddrRange
+	for _, r := range ranges {
+		if r.Len() > max {
+			logrus.Errorf("%s holds more than --max-addresses (%d) addresses. Skipping...", r, max)
+			continue
 		}
+		capped = append(capped, r)
 	}
+	return capped
+}
+
+// openAssetsSink opens the cypher-shell script if --write or --output is given,
+// or else the graph
+func openAssetsSink(cmd *cobra.Command) (graphSink.GraphSink, bool) {
+	if write || cmd.Flags().Changed("output") {
+		var (
+			sink *graphSink.FileSink
+			err  error
+		)
+		if resume {
+			logrus.Infof("Appending cypher script to %s", assetsOutput)
+			sink, err = graphSink.NewAppendFileSink(assetsOutput, batchSize)
+		} else {
+			logrus.Infof("Writing cypher script to %s", assetsOutput)
+			sink, err = graphSink.NewFileSink(assetsOutput, batchSize)
+		}
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to create %s", assetsOutput)
+			return nil, false
+		}
+		return sink, true
+	}
+
+	sink, err := openSink()
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+		return nil, false
+	}
+	return sink, true
+}
+
+// newProber returns the prober for --probe, or nil if addresses aren't probed.
+// It fails if the probes can't be sent, to IPv6 addresses too if ipv6 is set,
+// such as without CAP_NET_RAW.
+func newProber(ipv6 bool) (*traceroute.Prober, error) {
+	if len(probes) == 0 {
+		return nil, nil
+	}
+
+	prober := &traceroute.Prober{Timeout: probeTimeout}
+	for _, probe := range probes {
+		switch probe {
+		case "icmp":
+			prober.ICMP = true
+		case "tcp":
+			prober.Ports = probePorts
+		default:
+			return nil, fmt.Errorf("Unknown probe %q, expected icmp or tcp", probe)
+		}
+	}
+	if !prober.ICMP && len(prober.Ports) == 0 {
+		return nil, fmt.Errorf("--probe tcp needs at least one --probe-ports")
+	}
+	return prober, prober.Check(ipv6)
+}
+
+// checkpoint writes everything the sweep has resolved so far and then saves
+// its state, so the state never claims more than has been written
+func checkpoint(sink graphSink.GraphSink, state *trace2neolib.SweepState) error {
+	if err := sink.Flush(); err != nil {
+		logrus.WithError(err).Errorln("Failed to write to the graph.")
+		return err
+	}
+	if err := state.Save(stateFile); err != nil {
+		logrus.WithError(err).Errorf("Failed to save sweep state to %s", stateFile)
+		return err
+	}
+	return nil
 }
 
 func init() {
 	RootCmd.AddCommand(assetsCmd)
+	addTargetFlags(assetsCmd)
 
 	// Here you will define your flags and configuration settings.
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	assetsCmd.PersistentFlags().StringVarP(&username, "username", "u", "neo4j", "Neo4j username")
-	assetsCmd.PersistentFlags().StringVarP(&password, "password", "p", "Example", "Neo4j password")
-	assetsCmd.PersistentFlags().StringVarP(&host, "bolt-host", "b", "localhost", "Neo4j host")
-	assetsCmd.PersistentFlags().IntVarP(&port, "port", "o", 7687, "Neo4j port")
-
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
-	assetsCmd.Flags().BoolVarP(&write, "write", "w", false, "Write to file rather than to Neo4j directly")
+	assetsCmd.PersistentFlags().BoolVarP(&write, "write", "w", false, "Write to file rather than to Neo4j directly")
+	assetsCmd.Flags().IntVar(&concurrency, "concurrency", 16, "Number of DNS queries in flight at once")
+	assetsCmd.Flags().Float64Var(&qps, "qps", 0, "Maximum DNS queries per second sent to each server, 0 for no limit")
+	assetsCmd.Flags().StringSliceVar(&dnsServers, "dns-server", nil, "DNS servers to send PTR queries to, rather than the system resolver")
+	assetsCmd.Flags().BoolVar(&dnsTCP, "dns-tcp", false, "Query --dns-server over TCP rather than UDP")
+	assetsCmd.Flags().DurationVar(&dnsTimeout, "dns-timeout", 2*time.Second, "Timeout of each query to --dns-server")
+	assetsCmd.Flags().IntVar(&dnsRetries, "dns-retries", 2, "Number of times a query to --dns-server which timed out is retried")
+	assetsCmd.Flags().Uint64Var(&maxAddresses, "max-addresses", 1<<24, "Skip ranges of targets holding more addresses than this, such as large IPv6 prefixes")
+	assetsCmd.Flags().BoolVar(&fcrdns, "fcrdns", false, "Look up the names addresses resolve to, flagging PTR records which do not resolve back as stale")
+	assetsCmd.PersistentFlags().StringVar(&assetsOutput, "output", "assets.cypher", "Cypher script to write, implies --write")
+	assetsCmd.Flags().StringVar(&stateFile, "state-file", "assets.state.json", "File the progress of the sweep is saved to")
+	assetsCmd.Flags().StringVar(&progressMode, "progress", progress.ModeAuto, "How to report progress (auto, bar, log, none). auto draws a bar on a terminal and logs otherwise")
+	assetsCmd.Flags().DurationVar(&progressInterval, "progress-interval", 10*time.Second, "How often progress is logged with --progress log")
+	assetsCmd.Flags().StringVar(&reportFile, "report", "", "Write a JSON summary of the sweep to this file")
+	assetsCmd.Flags().BoolVar(&failOnErrors, "fail-on-errors", false, "Exit with status 2 if any lookup failed with an error other than NXDOMAIN or no answer")
+	assetsCmd.Flags().StringSliceVar(&probes, "probe", nil, "Probe whether each address is alive with icmp echo requests and/or tcp SYNs to --probe-ports. Needs root or CAP_NET_RAW")
+	assetsCmd.Flags().IntSliceVar(&probePorts, "probe-ports", []int{22, 80, 443}, "TCP ports probed with --probe tcp")
+	assetsCmd.Flags().DurationVar(&probeTimeout, "probe-timeout", time.Second, "How long to wait for each address to answer probes. Each of --concurrency workers waits the whole timeout on every address not answering every probe, so sweeps of mostly unused addresses take about addresses / --concurrency times this")
+	assetsCmd.Flags().BoolVar(&resume, "resume", false, "Resume the sweep saved in --state-file, appending to any --output script")
 
 }
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/kkirsche/trace2neo/trace2neolib"
+)
+
+func TestCapRanges(t *testing.T) {
+	var ranges []*trace2neolib.AddrRange
+	for _, s := range []string{"10.0.0.0/8", "10.0.0.0/7", "2001:db8::/64", "2001:db8::/104"} {
+		_, prefix, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges = append(ranges, trace2neolib.PrefixRange(prefix))
+	}
+
+	capped := capRanges(ranges, 1<<24)
+	if len(capped) != 2 || capped[0] != ranges[0] || capped[1] != ranges[3] {
+		t.Errorf("Got %v within 2^24 addresses, want %s and %s", capped, ranges[0], ranges[3])
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/spf13/cobra"
+)
+
+// atlasCmd represents the atlas command
+var atlasCmd = &cobra.Command{
+	Use:   "atlas",
+	Short: "Imports RIPE Atlas traceroute results into Neo4j",
+	Long: `Imports one or more RIPE Atlas traceroute result files into Neo4j. Both the
+JSON array returned by the results API and the one-result-per-line download
+format are supported. Use - to read results from stdin.
+
+Every result becomes a Trace node recording the Atlas measurement ID and probe
+ID it was seen by, and every responding hop a HOP relationship between
+Interface nodes. Files which can't be read are skipped, and the command exits
+with status 1 once the rest are imported.
+
+trace2neo atlas <results.json>
+
+trace2neo atlas <results.json> <results.json>
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if code := runAtlas(args); code != 0 {
+			os.Exit(code)
+		}
+	},
+}
+
+// runAtlas imports the Atlas result files, returning the exit code of the
+// command. Files which can't be read are skipped, but fail the command once the
+// rest are imported.
+func runAtlas(args []string) (code int) {
+	if verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	sink, err := openSink()
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+		return exitFailed
+	}
+	defer func() {
+		if closeSink(sink) != nil {
+			code = exitFailed
+		}
+	}()
+
+	for _, fp := range args {
+		results, err := readAtlasResults(fp)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to read Atlas results from %s. Skipping...", fp)
+			code = exitFailed
+			continue
+		}
+		logrus.Infof("Importing %d Atlas traceroutes from %s", len(results), fp)
+
+		for _, result := range results {
+			logrus.Debugf("Measurement %d, Probe %d: %d hops from %s to %s",
+				result.MeasurementID, result.ProbeID, len(result.Result), result.Source(), result.DstAddr)
+			if err = graphSink.WriteTrace(sink, result.Trace()); err != nil {
+				logrus.WithError(err).Errorln("Failed to write trace.")
+				return exitFailed
+			}
+		}
+	}
+	return code
+}
+
+func readAtlasResults(fp string) ([]trace2neolib.AtlasTraceroute, error) {
+	var r io.Reader = os.Stdin
+	if fp != "-" {
+		f, err := os.Open(fp)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return trace2neolib.ParseAtlasResults(r)
+}
+
+func init() {
+	RootCmd.AddCommand(atlasCmd)
+}
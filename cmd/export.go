@@ -0,0 +1,146 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphExport"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat, exportQuery, exportFile, exportASNFile string
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the topology as GraphML, GEXF or Graphviz DOT",
+	Long: `Exports the topology built from archived JSON Lines traces, or returned by a
+Cypher query against Neo4j, for use with Gephi, yEd or Graphviz. Hops are
+labelled with their RTT. With --asn-file, a prefix to AS mapping such as CAIDA's
+pfx2as, each Interface records the asn originating its address, and in DOT
+nodes are clustered by AS. Trace files which can't be read are skipped, and the
+command exits with status 1 once the rest are exported.
+
+trace2neo export --format graphml <traces.jsonl>
+
+trace2neo export --format dot --asn-file pfx2as.txt --query 'MATCH p=()-[:HOP]->() RETURN p'
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if code := runExport(args); code != 0 {
+			os.Exit(code)
+		}
+	},
+}
+
+// runExport exports the topology, returning the exit code of the command.
+// Trace files which can't be read are skipped, but fail the command once the
+// rest are exported.
+func runExport(args []string) (code int) {
+	if verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	if err := graphExport.CheckFormat(exportFormat); err != nil {
+		logrus.WithError(err).Errorln("Failed to export topology.")
+		return exitFailed
+	}
+
+	var asns *trace2neolib.ASNMap
+	if exportASNFile != "" {
+		f, err := os.Open(exportASNFile)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to open %s", exportASNFile)
+			return exitFailed
+		}
+		asns, err = trace2neolib.ReadASNMap(f)
+		f.Close()
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to read prefix to AS mapping from %s", exportASNFile)
+			return exitFailed
+		}
+	}
+
+	topology := graphSink.NewMemorySink()
+	if exportQuery != "" {
+		if err := checkBolt("export --query"); err != nil {
+			logrus.WithError(err).Errorln("Failed to open connection to Neo4j.")
+			return exitFailed
+		}
+		driver, err := openNeo4j()
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+			return exitFailed
+		}
+		err = graphSink.ReadQuery(context.Background(), driver, database, exportQuery, topology)
+		driver.Close(context.Background())
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to run query.")
+			return exitFailed
+		}
+	}
+
+	for _, fp := range args {
+		traces, err := readTraces(fp)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to read traces from %s. Skipping...", fp)
+			code = exitFailed
+			continue
+		}
+		for _, trace := range traces {
+			if err = graphSink.WriteTrace(topology, trace); err != nil {
+				logrus.WithError(err).Errorln("Failed to add trace.")
+				return exitFailed
+			}
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if exportFile != "-" {
+		f, err := os.Create(exportFile)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to create output file %s.", exportFile)
+			return exitFailed
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				logrus.WithError(err).Errorf("Failed to write output file %s.", exportFile)
+				code = exitFailed
+			}
+		}()
+		out = f
+	}
+
+	if err := graphExport.Write(out, exportFormat, topology, asns); err != nil {
+		logrus.WithError(err).Errorln("Failed to export topology.")
+		return exitFailed
+	}
+	return code
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "graphml", "Export format ("+strings.Join(graphExport.Formats, ", ")+")")
+	exportCmd.Flags().StringVar(&exportQuery, "query", "", "Cypher query against Neo4j returning the nodes, relationships or paths to export")
+	exportCmd.Flags().StringVar(&exportFile, "output-file", "-", "File to export to, - for stdout")
+	exportCmd.Flags().StringVar(&exportASNFile, "asn-file", "", "Prefix to AS mapping, one \"<cidr> <asn>\" or pfx2as \"<addr> <length> <asn>\" per line, used to record and cluster by the AS of each address")
+}
@@ -0,0 +1,99 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Imports archived JSON Lines traces into Neo4j",
+	Long: `Imports traces previously written with --output jsonl into Neo4j. Use - to
+read traces from stdin. Traces keep their original IDs, so importing the same
+file twice does not duplicate them. Files which can't be read are skipped, and
+the command exits with status 1 once the rest are imported.
+
+trace2neo import <traces.jsonl>
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if code := runImport(args); code != 0 {
+			os.Exit(code)
+		}
+	},
+}
+
+// runImport imports the trace files, returning the exit code of the command.
+// Files which can't be read are skipped, but fail the command once the rest
+// are imported.
+func runImport(args []string) (code int) {
+	if verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	sink, err := openSink()
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+		return exitFailed
+	}
+	defer func() {
+		if closeSink(sink) != nil {
+			code = exitFailed
+		}
+	}()
+
+	for _, fp := range args {
+		traces, err := readTraces(fp)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to read traces from %s. Skipping...", fp)
+			code = exitFailed
+			continue
+		}
+		logrus.Infof("Importing %d traces from %s", len(traces), fp)
+
+		for _, trace := range traces {
+			if err = graphSink.WriteTrace(sink, trace); err != nil {
+				logrus.WithError(err).Errorln("Failed to write trace.")
+				return exitFailed
+			}
+		}
+	}
+	return code
+}
+
+func readTraces(fp string) ([]*trace2neolib.Trace, error) {
+	var r io.Reader = os.Stdin
+	if fp != "-" {
+		f, err := os.Open(fp)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return trace2neolib.ReadTracesJSONL(r)
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,107 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// openNeo4j creates a driver for Neo4j using the connection flags and checks
+// that the server, or for neo4j:// URIs the cluster, can be reached
+func openNeo4j() (neo4j.DriverWithContext, error) {
+	target := uri
+	if target == "" {
+		target = fmt.Sprintf("bolt://%s:%d", host, port)
+	}
+
+	var roots *x509.CertPool
+	if caCert != "" {
+		pem, err := ioutil.ReadFile(caCert)
+		if err != nil {
+			return nil, err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", caCert)
+		}
+	}
+
+	driver, err := neo4j.NewDriverWithContext(target, neo4j.BasicAuth(username, password, ""), func(c *neo4j.Config) {
+		if roots != nil {
+			c.RootCAs = roots
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = driver.VerifyConnectivity(context.Background()); err != nil {
+		driver.Close(context.Background())
+		return nil, err
+	}
+	return driver, nil
+}
+
+// checkBolt refuses command, which only talks to Neo4j over Bolt, when
+// --transport http was given, rather than connecting over Bolt regardless
+func checkBolt(command string) error {
+	if transport != "bolt" {
+		return fmt.Errorf("%s is not supported over --transport %s. Use --transport bolt.", command, transport)
+	}
+	return nil
+}
+
+// openSink opens a graph sink writing to Neo4j over the --transport using the
+// connection flags, or to neo4j-admin import files if --csv-dir is set
+func openSink() (graphSink.GraphSink, error) {
+	if csvDir != "" {
+		logrus.Infof("Writing neo4j-admin import files to %s. Run %s/import.sh to import them.", csvDir, csvDir)
+		return graphSink.NewCSVSink(csvDir, database)
+	}
+
+	switch transport {
+	case "bolt":
+		driver, err := openNeo4j()
+		if err != nil {
+			return nil, err
+		}
+		return graphSink.NewBoltSink(driver, database, batchSize), nil
+	case "http":
+		url := httpURL
+		if url == "" {
+			url = fmt.Sprintf("http://%s:7474", host)
+		}
+		return graphSink.NewHTTPSink(url, database, username, password, batchSize), nil
+	default:
+		return nil, fmt.Errorf("Unsupported transport %s. Use bolt or http.", transport)
+	}
+}
+
+// closeSink closes sink, logging and returning the error if its last writes
+// fail
+func closeSink(sink graphSink.GraphSink) error {
+	err := sink.Close()
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to write to the graph.")
+	}
+	return err
+}
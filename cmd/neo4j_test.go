@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckBolt(t *testing.T) {
+	defer func(previous string) { transport = previous }(transport)
+
+	transport = "bolt"
+	if err := checkBolt("schema init"); err != nil {
+		t.Errorf("Got error %s over Bolt, want none", err)
+	}
+	transport = "http"
+	if err := checkBolt("schema init"); err == nil || !strings.Contains(err.Error(), "schema init is not supported over --transport http") {
+		t.Errorf("Got error %v over HTTP, want schema init refused", err)
+	}
+}
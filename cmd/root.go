@@ -16,53 +16,110 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"os"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
 	"github.com/kkirsche/trace2neo/trace2neolib"
 	"github.com/spf13/cobra"
 )
 
-var verbose bool
+var (
+	verbose                  bool
+	outputFormat, outputFile string
+	username, password, host string
+	csvDir                   string
+	transport, httpURL       string
+	uri, caCert, database    string
+	port, batchSize          int
+)
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "trace2neo",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Short: "Traceroutes hosts and records the paths in Neo4j",
+	Long: `Traceroutes one or more hosts and records each path in Neo4j as a Trace node,
+linked from the host the trace was run from to its target, and a chain of HOP
+relationships between the Interface nodes seen along the way.
+
+Targets may be addresses, hostnames, CIDR blocks or ranges such as
+10.0.0.1-10.0.0.50, given as args or read from --targets-file. Anything
+matching --exclude or --exclude-file is never traced.
+
+Use --output jsonl to write the traces to a file or stdout instead.
+
+trace2neo <ip> <ip>
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+trace2neo --targets-file scope.txt --exclude-file never.txt
+`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
 		if verbose {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
-		for _, ip := range args {
-			netip := net.ParseIP(ip)
-			if netip == nil {
-				logrus.Errorf("Argument %s was not an IP. Skipping...", ip)
-				continue
-			}
-			result, err := trace2neolib.RunTraceroute(netip)
-			if err != nil {
-				logrus.WithError(err).Errorln("Failed to run traceroute.")
-				continue
-			}
-
-			processedResults, err := trace2neolib.ProcessTracerouteOutput(result)
+		var (
+			out  io.Writer
+			sink graphSink.GraphSink
+			err  error
+		)
+		switch outputFormat {
+		case "":
+			sink, err = openSink()
 			if err != nil {
-				logrus.WithError(err).Errorln("Failed to process traceroute output.")
+				logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+				return
 			}
-			for _, processedResult := range processedResults {
-				logrus.Debugf("Hop: %s, Destination: %s, DNS Host: %s, IP: %s, RTT1: %s, RTT2: %s, RTT3: %s",
-					processedResult.Hop, processedResult.Destination, processedResult.DNSName, processedResult.IP,
-					processedResult.RTT1, processedResult.RTT2, processedResult.RTT3)
+			defer closeSink(sink)
+		case "jsonl":
+			out = os.Stdout
+			if outputFile != "-" {
+				f, err := os.Create(outputFile)
+				if err != nil {
+					logrus.WithError(err).Errorf("Failed to create output file %s.", outputFile)
+					return
+				}
+				defer f.Close()
+				out = f
 			}
+		default:
+			logrus.Errorf("Unsupported output format %s. Use jsonl.", outputFormat)
+			return
+		}
+
+		targets, _, err := loadTargets(args)
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to load targets.")
+			return
+		}
+
+		for _, r := range targets.Ranges() {
+			r.Each(func(netip net.IP) bool {
+				trace, err := trace2neolib.TraceHost(netip)
+				if err != nil {
+					logrus.WithError(err).Errorln("Failed to run traceroute.")
+					return true
+				}
+
+				for _, hop := range trace.Hops {
+					for _, reply := range hop.Replies {
+						logrus.Debugf("Hop: %d, Destination: %s, DNS Host: %s, IP: %s, RTT: %s, Timeout: %t, Annotations: %v",
+							hop.TTL, trace.Target, reply.Name, reply.IP, reply.RTT, reply.Timeout, reply.Annotations)
+					}
+				}
+
+				if out != nil {
+					err = trace2neolib.WriteTraceJSONL(out, trace)
+				} else {
+					err = graphSink.WriteTrace(sink, trace)
+				}
+				if err != nil {
+					logrus.WithError(err).Errorln("Failed to write trace.")
+				}
+				return true
+			})
 		}
 	},
 }
@@ -77,6 +134,8 @@ func Execute() {
 }
 
 func init() {
+	addTargetFlags(RootCmd)
+
 	// Here you will define your flags and configuration settings.
 	// Cobra supports Persistent Flags, which, if defined here,
 	// will be global for your application.
@@ -84,4 +143,18 @@ func init() {
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose mode")
+	RootCmd.PersistentFlags().StringVarP(&username, "username", "u", "neo4j", "Neo4j username")
+	RootCmd.PersistentFlags().StringVarP(&password, "password", "p", "Example", "Neo4j password")
+	RootCmd.PersistentFlags().StringVarP(&host, "bolt-host", "b", "localhost", "Neo4j host")
+	RootCmd.PersistentFlags().IntVarP(&port, "port", "o", 7687, "Neo4j port")
+	RootCmd.PersistentFlags().StringVar(&transport, "transport", "bolt", "Protocol used to write to Neo4j (bolt, http)")
+	RootCmd.PersistentFlags().StringVar(&httpURL, "http-url", "", "Neo4j HTTP URL used by --transport http (default http://<bolt-host>:7474)")
+	RootCmd.PersistentFlags().StringVar(&uri, "uri", "", "Neo4j URI, such as neo4j+s://cluster.example.com for a TLS cluster (default bolt://<bolt-host>:<port>)")
+	RootCmd.PersistentFlags().StringVar(&caCert, "ca-cert", "", "PEM file of CA certificates trusted for bolt+s and neo4j+s URIs")
+	RootCmd.PersistentFlags().StringVar(&database, "database", "", "Neo4j database to use (default the server's default database)")
+	RootCmd.PersistentFlags().StringVar(&csvDir, "csv-dir", "", "Write neo4j-admin import CSV files to this directory rather than to Neo4j")
+	RootCmd.PersistentFlags().IntVar(&batchSize, "batch-size", graphSink.DefaultBatchSize, "Number of rows written to Neo4j per transaction")
+
+	RootCmd.Flags().StringVar(&outputFormat, "output", "", "Write traces in this format (jsonl) rather than to Neo4j")
+	RootCmd.Flags().StringVar(&outputFile, "output-file", "-", "File to write traces to, - for stdout")
 }
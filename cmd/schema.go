@@ -0,0 +1,127 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/spf13/cobra"
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Manages the Neo4j constraints and indexes trace2neo relies on",
+	Long: `Manages the uniqueness constraints and indexes on every label trace2neo
+writes. Run schema init once against a new database before loading data.
+
+trace2neo schema init
+
+trace2neo schema check
+`,
+}
+
+// schemaInitCmd represents the schema init command
+var schemaInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Creates the constraints and indexes trace2neo relies on",
+	Long: `Creates any missing uniqueness constraints and indexes. Existing ones are
+left alone, so init can be run repeatedly.
+
+trace2neo schema init
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if verbose {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+
+		if err := checkBolt("schema init"); err != nil {
+			logrus.WithError(err).Errorln("Failed to open connection to Neo4j.")
+			os.Exit(1)
+		}
+		driver, err := openNeo4j()
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+			os.Exit(1)
+		}
+		err = graphSink.InitSchema(context.Background(), driver, database)
+		driver.Close(context.Background())
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to initialise schema.")
+			os.Exit(1)
+		}
+		logrus.Infoln("Schema is up to date.")
+	},
+}
+
+// schemaCheckCmd represents the schema check command
+var schemaCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Reports drift between the Neo4j schema and the one trace2neo expects",
+	Long: `Reports constraints and indexes which are missing from Neo4j or which
+trace2neo does not expect. Exits with status 1 if the schema has drifted.
+
+trace2neo schema check
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if verbose {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+
+		if err := checkBolt("schema check"); err != nil {
+			logrus.WithError(err).Errorln("Failed to open connection to Neo4j.")
+			os.Exit(1)
+		}
+		driver, err := openNeo4j()
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+			os.Exit(1)
+		}
+		drift, err := graphSink.CheckSchema(context.Background(), driver, database)
+		driver.Close(context.Background())
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to read schema.")
+			os.Exit(1)
+		}
+
+		for _, c := range drift.MissingConstraints {
+			logrus.Warnf("Missing uniqueness constraint on %s", c)
+		}
+		for _, c := range drift.UnexpectedConstraints {
+			logrus.Warnf("Unexpected uniqueness constraint on %s", c)
+		}
+		for _, i := range drift.MissingIndexes {
+			logrus.Warnf("Missing index on %s", i)
+		}
+		for _, i := range drift.UnexpectedIndexes {
+			logrus.Warnf("Unexpected index on %s", i)
+		}
+
+		if !drift.Empty() {
+			logrus.Errorln("Schema has drifted. Run trace2neo schema init to create what is missing.")
+			os.Exit(1)
+		}
+		logrus.Infoln("Schema is up to date.")
+	},
+}
+
+func init() {
+	schemaCmd.AddCommand(schemaInitCmd)
+	schemaCmd.AddCommand(schemaCheckCmd)
+	RootCmd.AddCommand(schemaCmd)
+}
@@ -0,0 +1,157 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+)
+
+// Statuses of a sweep
+const (
+	sweepCompleted   = "completed"
+	sweepInterrupted = "interrupted"
+	sweepFailed      = "failed"
+)
+
+// Exit codes of the assets command
+const (
+	exitFailed         = 1
+	exitLookupFailures = 2
+)
+
+// sweepSummary is the outcome of an assets sweep, logged when it ends and
+// written to --report for automation
+type sweepSummary struct {
+	Status              string         `json:"status"`
+	StartedAt           time.Time      `json:"started_at"`
+	FinishedAt          time.Time      `json:"finished_at"`
+	DurationSeconds     float64        `json:"duration_seconds"`
+	Targets             uint64         `json:"targets"`
+	Scanned             int            `json:"scanned"`
+	Resolved            int            `json:"resolved"`
+	Failed              int            `json:"failed"`
+	FailedByReason      map[string]int `json:"failed_by_reason"`
+	StalePTRs           int            `json:"stale_ptrs"`
+	Alive               int            `json:"alive"`
+	ProbeFailed         int            `json:"probe_failed"`
+	NodeUpserts         int            `json:"node_upserts"`
+	RelationshipUpserts int            `json:"relationship_upserts"`
+	AddressesPerSecond  float64        `json:"addresses_per_second"`
+}
+
+func newSweepSummary(targets uint64) *sweepSummary {
+	return &sweepSummary{
+		Status:         sweepCompleted,
+		StartedAt:      time.Now().UTC(),
+		Targets:        targets,
+		FailedByReason: make(map[string]int),
+	}
+}
+
+// record counts the result of resolving one address
+func (s *sweepSummary) record(result trace2neolib.SweepResult, assets []*trace2neolib.Asset) {
+	s.Scanned++
+	if result.Err != nil {
+		s.Failed++
+		s.FailedByReason[trace2neolib.FailureReason(result.Err)]++
+	} else if result.Resolved != nil && len(result.Resolved.Names) > 0 {
+		s.Resolved++
+	}
+	for _, asset := range assets {
+		if asset.StalePTR() {
+			s.StalePTRs++
+		}
+	}
+	if result.Liveness != nil && result.Liveness.Alive {
+		s.Alive++
+	}
+	if result.ProbeErr != nil {
+		s.ProbeFailed++
+	}
+}
+
+// finish records when the sweep ended and how many upserts were made to sink
+func (s *sweepSummary) finish(sink *graphSink.CountingSink) {
+	s.FinishedAt = time.Now().UTC()
+	duration := s.FinishedAt.Sub(s.StartedAt)
+	s.DurationSeconds = duration.Seconds()
+	if duration > 0 {
+		s.AddressesPerSecond = float64(s.Scanned) / duration.Seconds()
+	}
+	s.NodeUpserts = sink.NodeUpserts
+	s.RelationshipUpserts = sink.RelationshipUpserts
+}
+
+// lookupErrors returns the number of lookups which failed because of an error,
+// rather than because the address has no name
+func (s *sweepSummary) lookupErrors() int {
+	n := s.Failed
+	n -= s.FailedByReason[trace2neolib.ReasonNXDomain]
+	n -= s.FailedByReason[trace2neolib.ReasonNoAnswer]
+	return n
+}
+
+// exitCode returns the exit code of the sweep. Sweeps which failed or were
+// interrupted exit with exitFailed. With failOnErrors, completed sweeps in
+// which lookups failed because of an error exit with exitLookupFailures.
+func (s *sweepSummary) exitCode(failOnErrors bool) int {
+	switch {
+	case s.Status != sweepCompleted:
+		return exitFailed
+	case failOnErrors && s.lookupErrors() > 0:
+		return exitLookupFailures
+	default:
+		return 0
+	}
+}
+
+func (s *sweepSummary) log() {
+	fields := logrus.Fields{
+		"status":               s.Status,
+		"duration":             time.Duration(s.DurationSeconds * float64(time.Second)).String(),
+		"targets":              s.Targets,
+		"scanned":              s.Scanned,
+		"resolved":             s.Resolved,
+		"failed":               s.Failed,
+		"stale_ptrs":           s.StalePTRs,
+		"alive":                s.Alive,
+		"probe_failed":         s.ProbeFailed,
+		"node_upserts":         s.NodeUpserts,
+		"relationship_upserts": s.RelationshipUpserts,
+		"addresses_per_second": s.AddressesPerSecond,
+	}
+	for reason, n := range s.FailedByReason {
+		fields["failed_"+reason] = n
+	}
+	logrus.WithFields(fields).Infoln("Sweep summary")
+	if s.ProbeFailed > 0 {
+		logrus.Warnf("Failed to probe %d addresses, so whether they are alive is not recorded. Run with -v to see why.", s.ProbeFailed)
+	}
+}
+
+// writeReport writes the summary to fp as JSON
+func (s *sweepSummary) writeReport(fp string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, append(b, '\n'), 0644)
+}
@@ -0,0 +1,129 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	targetsFiles []string
+	excludes     []string
+	excludeFiles []string
+)
+
+// addTargetFlags adds the flags choosing targets beyond those given as args
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&targetsFiles, "targets-file", nil, "File of targets, one or more per line, or - for stdin")
+	cmd.Flags().StringSliceVar(&excludes, "exclude", nil, "Targets never to touch")
+	cmd.Flags().StringSliceVar(&excludeFiles, "exclude-file", nil, "File of targets never to touch, or - for stdin")
+}
+
+// loadTargets returns the addresses of the targets given as args and in
+// --targets-file, less those in --exclude and --exclude-file, along with the
+// CIDR blocks among the targets. Targets which can't be parsed or looked up
+// are skipped, but exclusions which can't be are an error, so nothing which
+// should be excluded is ever touched.
+func loadTargets(args []string) (*trace2neolib.AddrSet, []*net.IPNet, error) {
+	// stdin can only be read once, so reading it for both lists would leave
+	// the second empty
+	stdin := 0
+	for _, fp := range append(append([]string{}, targetsFiles...), excludeFiles...) {
+		if fp == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return nil, nil, fmt.Errorf("Only one of --targets-file and --exclude-file can be -, stdin can only be read once")
+	}
+
+	include, err := readTargetList(args, targetsFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	exclude, err := readTargetList(excludes, excludeFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	set := &trace2neolib.AddrSet{}
+	var subnets []*net.IPNet
+	for _, s := range include {
+		target, err := trace2neolib.ParseTarget(s)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to parse target %s. Skipping...", s)
+			continue
+		}
+		ranges, err := target.Ranges(false, net.LookupHost)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to look up target %s. Skipping...", s)
+			continue
+		}
+		for _, r := range ranges {
+			set.Add(r)
+		}
+		if target.Prefix != nil {
+			subnets = append(subnets, target.Prefix)
+		}
+	}
+
+	for _, s := range exclude {
+		target, err := trace2neolib.ParseTarget(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse exclusion %s: %s", s, err)
+		}
+		ranges, err := target.Ranges(true, net.LookupHost)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to look up exclusion %s: %s", s, err)
+		}
+		for _, r := range ranges {
+			set.Remove(r)
+		}
+	}
+	return set, subnets, nil
+}
+
+// readTargetList splits targets given on the command line and reads those in
+// files, with - reading stdin
+func readTargetList(targets, files []string) ([]string, error) {
+	var list []string
+	for _, t := range targets {
+		list = append(list, trace2neolib.SplitTargets(t)...)
+	}
+	for _, fp := range files {
+		var r io.Reader = os.Stdin
+		if fp != "-" {
+			f, err := os.Open(fp)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		read, err := trace2neolib.ReadTargets(r)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s: %s", fp, err)
+		}
+		list = append(list, read...)
+	}
+	return list, nil
+}
@@ -0,0 +1,125 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/kkirsche/trace2neo/warts"
+	"github.com/spf13/cobra"
+)
+
+// wartsCmd represents the warts command
+var wartsCmd = &cobra.Command{
+	Use:   "warts",
+	Short: "Imports scamper warts files into Neo4j",
+	Long: `Imports one or more binary warts files collected with scamper into Neo4j.
+Use - to read a warts file from stdin.
+
+Traceroutes and MDA traceroutes (tracelb) become Trace nodes with HOP
+relationships between Interface nodes. Ping results are recorded on the
+Interface node which was pinged. Files which can't be imported are skipped,
+and the command exits with status 1 once the rest are imported.
+
+trace2neo warts <file.warts>
+
+trace2neo warts <file.warts> <file.warts>
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if code := runWarts(args); code != 0 {
+			os.Exit(code)
+		}
+	},
+}
+
+// runWarts imports the warts files, returning the exit code of the command.
+// Files which can't be imported are skipped, but fail the command once the
+// rest are imported.
+func runWarts(args []string) (code int) {
+	if verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	sink, err := openSink()
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to open connection to Neo4j. Is it running?")
+		return exitFailed
+	}
+	defer func() {
+		if closeSink(sink) != nil {
+			code = exitFailed
+		}
+	}()
+
+	for _, fp := range args {
+		logrus.Infof("Importing warts file %s", fp)
+		count, err := importWartsFile(sink, fp)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to import %s after %d objects.", fp, count)
+			code = exitFailed
+			continue
+		}
+		logrus.Infof("Imported %d objects from %s", count, fp)
+	}
+	return code
+}
+
+func importWartsFile(sink graphSink.GraphSink, fp string) (int, error) {
+	var r io.Reader = os.Stdin
+	if fp != "-" {
+		f, err := os.Open(fp)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	count := 0
+	wr := warts.NewReader(r)
+	for {
+		obj, err := wr.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		switch o := obj.(type) {
+		case *warts.Trace:
+			logrus.Debugf("Trace from %s to %s: %d replies", o.Src, o.Dst, len(o.Hops))
+			err = graphSink.WriteTrace(sink, trace2neolib.WartsTrace(o))
+		case *warts.Tracelb:
+			logrus.Debugf("MDA trace from %s to %s: %d nodes, %d links", o.Src, o.Dst, len(o.Nodes), len(o.Links))
+			err = graphSink.WriteTracelb(sink, o)
+		case *warts.Ping:
+			logrus.Debugf("Ping to %s: %d sent, %d replies", o.Dst, o.Sent, len(o.Replies))
+			err = graphSink.WritePing(sink, o)
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(wartsCmd)
+}
@@ -0,0 +1,128 @@
+// Copyright © 2016 Kevin Kirsche <kev.kirsche[at]gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	zoneOrigin   string
+	zoneTransfer string
+	zoneSerial   uint32
+)
+
+// assetsZoneCmd represents the assets zone command
+var assetsZoneCmd = &cobra.Command{
+	Use:   "zone",
+	Short: "Loads assets from DNS zone files or zone transfers",
+	Long: `Loads the records of BIND zone files as assets. A and AAAA records become
+RESOLVES_TO relationships from Hosts to Interfaces flagged forward, PTR records
+HAS_INTERFACE and RESOLVES_TO relationships flagged ptr, and CNAME records CNAME
+relationships between Hosts. With --transfer the zones are transferred from a
+DNS server with AXFR instead, or with IXFR if --serial is given, loading only
+the records added since that serial.
+
+trace2neo assets zone --origin example.com db.example.com
+
+trace2neo assets zone --transfer ns1.example.com example.com 10.in-addr.arpa
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if code := runZone(cmd, args); code != 0 {
+			os.Exit(code)
+		}
+	},
+}
+
+// runZone loads the zones, returning the exit code of the command. Zones which
+// can't be loaded are skipped, but fail the command once the rest are loaded.
+func runZone(cmd *cobra.Command, args []string) (code int) {
+	if verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	sink, ok := openAssetsSink(cmd)
+	if !ok {
+		return exitFailed
+	}
+	defer func() {
+		if err := sink.Close(); err != nil {
+			logrus.WithError(err).Errorln("Failed to write to the graph.")
+			code = exitFailed
+		}
+	}()
+
+	var assets, aliases int
+	var writeErr error
+	handle := func(record trace2neolib.ZoneRecord) {
+		if writeErr != nil {
+			return
+		}
+		switch record.Type {
+		case "CNAME":
+			writeErr = graphSink.WriteAlias(sink, record.Name, record.Value)
+			aliases++
+		case "PTR":
+			writeErr = graphSink.WriteAsset(sink, record.Asset())
+			assets++
+		default:
+			writeErr = graphSink.WriteResolution(sink, record.Name, record.Value)
+			assets++
+		}
+	}
+
+	for _, arg := range args {
+		var err error
+		if zoneTransfer != "" {
+			logrus.Infof("Transferring %s from %s", arg, zoneTransfer)
+			err = trace2neolib.TransferZone(zoneTransfer, arg, zoneSerial, handle)
+		} else {
+			logrus.Infof("Reading zone file %s", arg)
+			err = readZoneFile(arg, handle)
+		}
+		if writeErr != nil {
+			logrus.WithError(writeErr).Errorf("Failed to write the records of %s", arg)
+			return exitFailed
+		}
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to load %s. Skipping...", arg)
+			code = exitFailed
+		}
+	}
+	logrus.Infof("Loaded %d assets and %d aliases.", assets, aliases)
+	return code
+}
+
+func readZoneFile(fp string, handle func(trace2neolib.ZoneRecord)) error {
+	f, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return trace2neolib.ReadZone(f, zoneOrigin, fp, handle)
+}
+
+func init() {
+	assetsCmd.AddCommand(assetsZoneCmd)
+
+	assetsZoneCmd.Flags().StringVar(&zoneOrigin, "origin", "", "Origin of zone files which do not set $ORIGIN")
+	assetsZoneCmd.Flags().StringVar(&zoneTransfer, "transfer", "", "DNS server to transfer the zones from, rather than reading zone files")
+	assetsZoneCmd.Flags().Uint32Var(&zoneSerial, "serial", 0, "Transfer only the changes since this serial with IXFR")
+}
@@ -0,0 +1,177 @@
+package cypherBuilder
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Literal renders v as a Cypher literal. Strings are quoted and escaped, so
+// values such as hostnames taken from DNS can never break out of the literal.
+// Slices become lists, maps become maps, and values Cypher cannot represent,
+// such as NaN, become null.
+func Literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return String(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "null"
+		}
+		// keep a decimal point, so whole numbers are still stored as floats
+		f := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(f, ".") {
+			f += ".0"
+		}
+		return f
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return list(items)
+	case []interface{}:
+		return list(v)
+	case map[string]interface{}:
+		return literalMap(v)
+	default:
+		return String(fmt.Sprint(v))
+	}
+}
+
+func list(items []interface{}) string {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(Literal(item))
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+func literalMap(m map[string]interface{}) string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(Identifier(key))
+		buf.WriteString(": ")
+		buf.WriteString(Literal(m[key]))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// String renders s as a double quoted Cypher string literal. Quotes,
+// backslashes and control characters are escaped, and invalid UTF-8 is
+// replaced with U+FFFD.
+func String(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f || r == utf8.RuneError || r == 0x2028 || r == 0x2029 {
+				if r == utf8.RuneError {
+					r = 0xfffd
+				}
+				fmt.Fprintf(&buf, `\u%04x`, r)
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// Identifier renders name as a Cypher identifier, such as a variable, label,
+// relationship type or property key, quoting it in backticks unless it is a
+// plain identifier
+func Identifier(name string) string {
+	if isPlainIdentifier(name) {
+		return name
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('`')
+	for _, r := range name {
+		if r == '`' {
+			buf.WriteString("``")
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	buf.WriteByte('`')
+	return buf.String()
+}
+
+// reserved are the Cypher keywords which are quoted even where Neo4j would
+// accept them unquoted
+var reserved = map[string]bool{
+	"ALL": true, "AND": true, "AS": true, "ASC": true, "BY": true, "CALL": true,
+	"CASE": true, "CONSTRAINT": true, "CONTAINS": true, "CREATE": true,
+	"DELETE": true, "DESC": true, "DETACH": true, "DISTINCT": true, "DROP": true,
+	"ELSE": true, "END": true, "EXISTS": true, "FALSE": true, "FOR": true,
+	"IN": true, "INDEX": true, "IS": true, "LIMIT": true, "MATCH": true,
+	"MERGE": true, "NOT": true, "NULL": true, "ON": true, "OPTIONAL": true,
+	"OR": true, "ORDER": true, "REMOVE": true, "RETURN": true, "SET": true,
+	"SKIP": true, "THEN": true, "TRUE": true, "UNION": true, "UNIQUE": true,
+	"UNWIND": true, "WHEN": true, "WHERE": true, "WITH": true, "XOR": true,
+	"YIELD": true,
+}
+
+func isPlainIdentifier(name string) bool {
+	if name == "" || reserved[strings.ToUpper(name)] {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
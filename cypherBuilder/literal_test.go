@@ -0,0 +1,180 @@
+package cypherBuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// names are seeds for the fuzz tests: DNS names, and names built to break out
+// of literals and identifiers
+var names = []string{
+	"",
+	"host.example.com.",
+	"1.0.0.10.in-addr.arpa.",
+	`quote"d`,
+	"single'quoted",
+	`back\slash`,
+	`trailing\`,
+	"back`tick",
+	"``",
+	"new\nline",
+	"tab\tcarriage\rreturn",
+	"nul\x00bell\x07del\x7f",
+	"  ",
+	"non-utf8\xff\xfe",
+	"\xc3",
+	"héllo.例え.jp",
+	"MATCH",
+	"match",
+	"Return",
+	"x\"}) DETACH DELETE n //",
+}
+
+// parseString parses a Cypher string literal, returning the string it holds
+func parseString(lit string) (string, error) {
+	if len(lit) < 2 || lit[0] != '"' || lit[len(lit)-1] != '"' {
+		return "", fmt.Errorf("Not a double quoted string: %q", lit)
+	}
+	body := lit[1 : len(lit)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '"':
+			return "", fmt.Errorf("Unescaped quote at %d in %q", i, lit)
+		case c < 0x20 || c == 0x7f:
+			return "", fmt.Errorf("Unescaped control character at %d in %q", i, lit)
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i == len(body) {
+			return "", fmt.Errorf("Dangling backslash in %q", lit)
+		}
+		switch body[i] {
+		case '\\', '\'', '"':
+			b.WriteByte(body[i])
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if i+4 >= len(body) {
+				return "", fmt.Errorf("Short \\u escape in %q", lit)
+			}
+			r, err := strconv.ParseUint(body[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			return "", fmt.Errorf("Unknown escape \\%c in %q", body[i], lit)
+		}
+	}
+	return b.String(), nil
+}
+
+// parseIdentifier parses a Cypher identifier, returning the name it holds
+func parseIdentifier(ident string) (string, error) {
+	if !strings.HasPrefix(ident, "`") {
+		if !isPlainIdentifier(ident) {
+			return "", fmt.Errorf("Unquoted identifier %q is not plain", ident)
+		}
+		return ident, nil
+	}
+	if len(ident) < 2 || !strings.HasSuffix(ident, "`") {
+		return "", fmt.Errorf("Unterminated identifier %q", ident)
+	}
+
+	body := ident[1 : len(ident)-1]
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] == '`' {
+			if i+1 == len(body) || body[i+1] != '`' {
+				return "", fmt.Errorf("Unescaped backtick at %d in %q", i, ident)
+			}
+			i++
+		}
+		b.WriteByte(body[i])
+	}
+	return b.String(), nil
+}
+
+// valid returns s as it is stored once rendered, with each byte of invalid
+// UTF-8 replaced by U+FFFD
+func valid(s string) string {
+	return string([]rune(s))
+}
+
+func FuzzString(f *testing.F) {
+	for _, name := range names {
+		f.Add(name)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		lit := String(name)
+		if !utf8.ValidString(lit) {
+			t.Fatalf("String(%q) = %q, which is not valid UTF-8", name, lit)
+		}
+		got, err := parseString(lit)
+		if err != nil {
+			t.Fatalf("String(%q) = %q: %s", name, lit, err)
+		}
+		if want := valid(name); got != want {
+			t.Fatalf("String(%q) = %q, which parses as %q", name, lit, got)
+		}
+	})
+}
+
+func FuzzIdentifier(f *testing.F) {
+	for _, name := range names {
+		f.Add(name)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		ident := Identifier(name)
+		got, err := parseIdentifier(ident)
+		if err != nil {
+			t.Fatalf("Identifier(%q) = %q: %s", name, ident, err)
+		}
+		if want := valid(name); got != want {
+			t.Fatalf("Identifier(%q) = %q, which parses as %q", name, ident, got)
+		}
+		if reserved[strings.ToUpper(name)] && !strings.HasPrefix(ident, "`") {
+			t.Fatalf("Identifier(%q) = %q, leaving a reserved word unquoted", name, ident)
+		}
+	})
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{uint16(443), "443"},
+		{1.0, "1.0"},
+		{0.25, "0.25"},
+		{[]string{"a", `b"`}, `["a", "b\""]`},
+		{[]interface{}{22, 443}, "[22, 443]"},
+		{map[string]interface{}{"ip": "10.0.0.1", "first seen": 1}, "{`first seen`: 1, ip: \"10.0.0.1\"}"},
+	}
+	for _, test := range tests {
+		if got := Literal(test.in); got != test.want {
+			t.Errorf("Literal(%#v) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cypherBuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SeenClause returns the ON CREATE and ON MATCH clauses which keep the
+// first_seen and last_seen properties of variable spanning every time it has
+// been seen, seen being the Cypher expression for the time it was seen now.
+// Reruns and imports of older data only ever widen the span.
+func SeenClause(variable, seen string) string {
+	return fmt.Sprintf(`ON CREATE SET %[1]s.first_seen = %[2]s, %[1]s.last_seen = %[2]s
+ON MATCH SET %[1]s.first_seen = CASE WHEN %[1]s.first_seen IS NULL OR %[2]s < %[1]s.first_seen THEN %[2]s ELSE %[1]s.first_seen END,
+  %[1]s.last_seen = CASE WHEN %[1]s.last_seen IS NULL OR %[2]s > %[1]s.last_seen THEN %[2]s ELSE %[1]s.last_seen END`, variable, seen)
+}
+
+// MergeNode returns the statement which, for each row of an UNWIND, MERGEs the
+// node with label whose key properties match row.key, sets row.props on it and
+// widens its seen span to include row.seen
+func MergeNode(label string, key []string) string {
+	return fmt.Sprintf("MERGE (n:%s%s)\n%s\nSET n += row.props",
+		Identifier(label), keyPattern("row.key", key), SeenClause("n", "row.seen"))
+}
+
+// MergeRelationship returns the statement which, for each row of an UNWIND,
+// MERGEs the nodes matching row.from and row.to and the relationship of
+// relType between them matching row.key, setting row.props on the relationship.
+// All three are seen at row.seen.
+func MergeRelationship(fromLabel string, fromKey []string, relType string, key []string, toLabel string, toKey []string) string {
+	return fmt.Sprintf("MERGE (a:%s%s)\n%s\nMERGE (b:%s%s)\n%s\nMERGE (a)-[r:%s%s]->(b)\n%s\nSET r += row.props",
+		Identifier(fromLabel), keyPattern("row.from", fromKey), SeenClause("a", "row.seen"),
+		Identifier(toLabel), keyPattern("row.to", toKey), SeenClause("b", "row.seen"),
+		Identifier(relType), keyPattern("row.key", key), SeenClause("r", "row.seen"))
+}
+
+// keyPattern renders the key properties as a map pattern reading their values
+// from the map expression row
+func keyPattern(row string, key []string) string {
+	if len(key) == 0 {
+		return ""
+	}
+
+	var pattern []string
+	for _, k := range key {
+		pattern = append(pattern, fmt.Sprintf("%[1]s: %[2]s.%[1]s", Identifier(k), row))
+	}
+	return " {" + strings.Join(pattern, ", ") + "}"
+}
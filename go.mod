@@ -0,0 +1,30 @@
+module github.com/kkirsche/trace2neo
+
+go 1.26.0
+
+// logrus has since been renamed to github.com/sirupsen/logrus. Releases before
+// its go.mod was added can still be imported under the old name.
+replace github.com/Sirupsen/logrus => github.com/sirupsen/logrus v1.0.6
+
+require (
+	github.com/Sirupsen/logrus v0.0.0-00010101000000-000000000000
+	github.com/miekg/dns v1.1.72
+	github.com/neo4j/neo4j-go-driver/v5 v5.28.4
+	github.com/spf13/cobra v1.10.2
+	golang.org/x/net v0.60.0
+)
+
+require (
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/sirupsen/logrus v1.10.2 // indirect
+	github.com/spf13/pflag v1.0.9 // indirect
+	github.com/stretchr/testify v1.12.1 // indirect
+	golang.org/x/crypto v0.57.0 // indirect
+	golang.org/x/mod v0.31.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+	golang.org/x/term v0.46.0 // indirect
+	golang.org/x/tools v0.40.0 // indirect
+	gopkg.in/airbrake/gobrake.v2 v2.0.9 // indirect
+	gopkg.in/gemnasium/logrus-airbrake-hook.v2 v2.1.2 // indirect
+)
@@ -0,0 +1,61 @@
+package graphExport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kkirsche/trace2neo/graphSink"
+)
+
+// WriteDOT writes nodes and relationships as a Graphviz digraph. Nodes with an
+// asn property are clustered by AS, and hops are labelled with their RTT.
+func WriteDOT(w io.Writer, nodes []graphSink.Node, relationships []graphSink.Relationship) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph topology {\n")
+	bw.WriteString("  node [shape=box];\n")
+
+	clusters := make(map[string][]graphSink.Node)
+	var asns []string
+	for _, n := range nodes {
+		asn, ok := n.Properties["asn"]
+		if !ok || asn == nil {
+			writeDOTNode(bw, "  ", n)
+			continue
+		}
+		key := fmt.Sprint(asn)
+		if _, ok := clusters[key]; !ok {
+			asns = append(asns, key)
+		}
+		clusters[key] = append(clusters[key], n)
+	}
+	sort.Strings(asns)
+	for _, asn := range asns {
+		fmt.Fprintf(bw, "  subgraph %s {\n", dotID("cluster_AS"+asn))
+		fmt.Fprintf(bw, "    label=%s;\n", dotID("AS"+asn))
+		for _, n := range clusters[asn] {
+			writeDOTNode(bw, "    ", n)
+		}
+		bw.WriteString("  }\n")
+	}
+
+	for _, r := range relationships {
+		fmt.Fprintf(bw, "  %s -> %s [label=%s];\n", dotID(nodeID(r.From)), dotID(nodeID(r.To)), dotID(edgeLabel(r)))
+	}
+
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+func writeDOTNode(w *bufio.Writer, indent string, n graphSink.Node) {
+	fmt.Fprintf(w, "%s%s [label=%s];\n", indent, dotID(nodeID(n.Ref())), dotID(n.Label+"\n"+nodeName(n.Ref())))
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+
+// dotID quotes s as a DOT identifier. Newlines become line breaks in labels.
+func dotID(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,188 @@
+// Package graphExport writes the topology collected in a graphSink.MemorySink
+// in formats understood by graph tools other than Neo4j, such as Gephi, yEd and
+// Graphviz.
+package graphExport
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+)
+
+// Formats are the formats Write supports
+var Formats = []string{"graphml", "gexf", "dot"}
+
+// CheckFormat returns an error if Write does not support format
+func CheckFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unsupported export format %s. Use one of %s.", format, strings.Join(Formats, ", "))
+}
+
+// Write writes every node and relationship of m to w in format. If asns is
+// not nil, each Interface records the asn originating its address.
+func Write(w io.Writer, format string, m *graphSink.MemorySink, asns *trace2neolib.ASNMap) error {
+	nodes, relationships := m.AllNodes(), m.AllRelationships()
+	if asns != nil {
+		nodes = withASNs(nodes, asns)
+	}
+	switch format {
+	case "graphml":
+		return WriteGraphML(w, nodes, relationships)
+	case "gexf":
+		return WriteGEXF(w, nodes, relationships)
+	case "dot":
+		return WriteDOT(w, nodes, relationships)
+	default:
+		return CheckFormat(format)
+	}
+}
+
+// withASNs returns nodes with each Interface whose address asns maps to an AS
+// given an asn property. The properties of nodes are not modified.
+func withASNs(nodes []graphSink.Node, asns *trace2neolib.ASNMap) []graphSink.Node {
+	annotated := make([]graphSink.Node, len(nodes))
+	for i, n := range nodes {
+		annotated[i] = n
+		ip, _ := n.Key["ip"].(string)
+		if n.Label != "Interface" || net.ParseIP(ip) == nil {
+			continue
+		}
+		asn, ok := asns.Lookup(net.ParseIP(ip))
+		if !ok {
+			continue
+		}
+		properties := map[string]interface{}{"asn": asn}
+		for k, v := range n.Properties {
+			if k != "asn" {
+				properties[k] = v
+			}
+		}
+		annotated[i].Properties = properties
+	}
+	return annotated
+}
+
+// nodeID identifies a node across every label
+func nodeID(ref graphSink.NodeRef) string {
+	return ref.ID()
+}
+
+// nodeName is how a node is labelled when drawn: the values of its key
+func nodeName(ref graphSink.NodeRef) string {
+	var values []string
+	for _, key := range sortedKeys(ref.Key) {
+		values = append(values, fmt.Sprint(ref.Key[key]))
+	}
+	return strings.Join(values, " ")
+}
+
+// edgeLabel is how a relationship is labelled when drawn: the RTT of a hop,
+// or the relationship type
+func edgeLabel(r graphSink.Relationship) string {
+	if rtt, ok := r.Properties["rtt"].(float64); ok && r.Type == "HOP" {
+		return strconv.FormatFloat(rtt, 'f', 2, 64) + " ms"
+	}
+	return r.Type
+}
+
+// attribute is a property column shared by every node or every relationship
+type attribute struct {
+	name string
+	// kind is one of string, long, double or boolean
+	kind string
+}
+
+// attributes returns the properties found across every map in properties,
+// typed by the first value seen of each
+func attributes(properties []map[string]interface{}) []attribute {
+	kinds := make(map[string]string)
+	for _, p := range properties {
+		for key, value := range p {
+			if _, ok := kinds[key]; !ok && value != nil {
+				kinds[key] = kind(value)
+			}
+		}
+	}
+
+	var attrs []attribute
+	for name, kind := range kinds {
+		attrs = append(attrs, attribute{name: name, kind: kind})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].name < attrs[j].name })
+	return attrs
+}
+
+func kind(v interface{}) string {
+	switch v.(type) {
+	case int, int64, uint8, uint16, uint32:
+		return "long"
+	case float64:
+		return "double"
+	case bool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// value renders a property value as text, lists separated by ;
+func value(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ";")
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ";")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// nodeProperties returns the properties of a node, including its key and label
+func nodeProperties(n graphSink.Node) map[string]interface{} {
+	p := map[string]interface{}{"label": n.Label}
+	for k, v := range n.Properties {
+		p[k] = v
+	}
+	for k, v := range n.Key {
+		p[k] = v
+	}
+	return p
+}
+
+// relationshipProperties returns the properties of a relationship, including
+// its key and type
+func relationshipProperties(r graphSink.Relationship) map[string]interface{} {
+	p := map[string]interface{}{"type": r.Type}
+	for k, v := range r.Properties {
+		p[k] = v
+	}
+	for k, v := range r.Key {
+		p[k] = v
+	}
+	return p
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package graphExport
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kkirsche/trace2neo/graphSink"
+	"github.com/kkirsche/trace2neo/trace2neolib"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// topology returns nodes whose first value of responding_ports is a list and
+// of note is nil, a Host whose name needs escaping, and hops whose first rtt
+// is nil
+func topology(t *testing.T) ([]graphSink.Node, []graphSink.Relationship) {
+	router := graphSink.NodeRef{Label: "Interface", Key: map[string]interface{}{"ip": "10.0.0.1"}}
+	target := graphSink.NodeRef{Label: "Interface", Key: map[string]interface{}{"ip": "192.0.2.1"}}
+	source := graphSink.NodeRef{Label: "Interface", Key: map[string]interface{}{"ip": "198.51.100.1"}}
+	host := graphSink.NodeRef{Label: "Host", Key: map[string]interface{}{"name": `say "hi" <b>&`}}
+
+	nodes := []graphSink.Node{
+		{Label: router.Label, Key: router.Key, Properties: map[string]interface{}{
+			"responding_ports": []interface{}{int64(22), int64(443)},
+			"note":             nil,
+			"last_seen":        int64(1500000000),
+		}},
+		{Label: target.Label, Key: target.Key, Properties: map[string]interface{}{
+			"note":      "edge <router>",
+			"alive":     true,
+			"last_seen": int64(1500000001),
+		}},
+		{Label: source.Label, Key: source.Key},
+		{Label: host.Label, Key: host.Key},
+	}
+	relationships := []graphSink.Relationship{
+		{Type: "HOP", From: source, To: router, Key: map[string]interface{}{"trace_id": "t1"}, Properties: map[string]interface{}{"ttl": 1, "rtt": nil}},
+		{Type: "HOP", From: router, To: target, Key: map[string]interface{}{"trace_id": "t1"}, Properties: map[string]interface{}{"ttl": 2, "rtt": 12.5}},
+		{Type: "HAS_INTERFACE", From: host, To: router},
+	}
+
+	asns, err := trace2neolib.ReadASNMap(strings.NewReader("10.0.0.0/8 64512\n192.0.2.0 24 64513_64514\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return withASNs(nodes, asns), relationships
+}
+
+func TestGolden(t *testing.T) {
+	for format, write := range map[string]func(io.Writer, []graphSink.Node, []graphSink.Relationship) error{
+		"graphml": WriteGraphML,
+		"gexf":    WriteGEXF,
+		"dot":     WriteDOT,
+	} {
+		nodes, relationships := topology(t)
+		var buf bytes.Buffer
+		if err := write(&buf, nodes, relationships); err != nil {
+			t.Fatalf("Failed to write %s: %s", format, err)
+		}
+
+		golden := filepath.Join("testdata", "topology."+format)
+		if *update {
+			if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s output differs from %s:\n%s", format, golden, buf.String())
+		}
+	}
+}
+
+func TestWithASNs(t *testing.T) {
+	nodes, _ := topology(t)
+	for _, n := range nodes {
+		ip, _ := n.Key["ip"].(string)
+		want, ok := map[string]int{"10.0.0.1": 64512, "192.0.2.1": 64513}[ip]
+		if asn, has := n.Properties["asn"]; has != ok || (ok && asn != want) {
+			t.Errorf("Got asn %v for %s, want %d", asn, n.Ref().ID(), want)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	attrs := attributes([]map[string]interface{}{
+		{"ports": []interface{}{int64(22)}, "rtt": nil},
+		{"ports": []interface{}{}, "rtt": 1.5, "ttl": 3},
+	})
+	want := []attribute{{"ports", "string"}, {"rtt", "double"}, {"ttl", "long"}}
+	if len(attrs) != len(want) {
+		t.Fatalf("Got attributes %v, want %v", attrs, want)
+	}
+	for i := range want {
+		if attrs[i] != want[i] {
+			t.Errorf("Got attribute %v, want %v", attrs[i], want[i])
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, format := range Formats {
+		if err := CheckFormat(format); err != nil {
+			t.Errorf("Got %v for %s, want nil", err, format)
+		}
+	}
+	if err := CheckFormat("svg"); err == nil {
+		t.Errorf("Got nil for svg, want an error")
+	}
+}
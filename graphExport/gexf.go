@@ -0,0 +1,124 @@
+package graphExport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/kkirsche/trace2neo/graphSink"
+)
+
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+type gexfEdge struct {
+	ID        string         `xml:"id,attr"`
+	Source    string         `xml:"source,attr"`
+	Target    string         `xml:"target,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// WriteGEXF writes nodes and relationships as GEXF 1.3, with every property as
+// a typed attribute and hop RTTs as edge labels
+func WriteGEXF(w io.Writer, nodes []graphSink.Node, relationships []graphSink.Relationship) error {
+	var nodeProps, edgeProps []map[string]interface{}
+	for _, n := range nodes {
+		nodeProps = append(nodeProps, nodeProperties(n))
+	}
+	for _, r := range relationships {
+		edgeProps = append(edgeProps, relationshipProperties(r))
+	}
+	nodeAttrs, edgeAttrs := attributes(nodeProps), attributes(edgeProps)
+
+	doc := gexf{
+		Xmlns:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph: gexfGraph{
+			DefaultEdgeType: "directed",
+			Attributes: []gexfAttributes{
+				{Class: "node", Attributes: gexfAttributeList(nodeAttrs)},
+				{Class: "edge", Attributes: gexfAttributeList(edgeAttrs)},
+			},
+		},
+	}
+
+	for i, n := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:        nodeID(n.Ref()),
+			Label:     nodeName(n.Ref()),
+			AttValues: gexfValues(nodeAttrs, nodeProps[i]),
+		})
+	}
+	for i, r := range relationships {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:        fmt.Sprintf("e%d", i),
+			Source:    nodeID(r.From),
+			Target:    nodeID(r.To),
+			Label:     edgeLabel(r),
+			AttValues: gexfValues(edgeAttrs, edgeProps[i]),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func gexfAttributeList(attrs []attribute) []gexfAttribute {
+	var list []gexfAttribute
+	for i, a := range attrs {
+		list = append(list, gexfAttribute{ID: fmt.Sprint(i), Title: a.name, Type: a.kind})
+	}
+	return list
+}
+
+func gexfValues(attrs []attribute, properties map[string]interface{}) []gexfAttValue {
+	var values []gexfAttValue
+	for i, a := range attrs {
+		if v, ok := properties[a.name]; ok && v != nil {
+			values = append(values, gexfAttValue{For: fmt.Sprint(i), Value: value(v)})
+		}
+	}
+	return values
+}
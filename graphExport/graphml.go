@@ -0,0 +1,109 @@
+package graphExport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/kkirsche/trace2neo/graphSink"
+)
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes nodes and relationships as GraphML. Every property
+// becomes a typed data key, and edges carry a label key with the hop RTT.
+func WriteGraphML(w io.Writer, nodes []graphSink.Node, relationships []graphSink.Relationship) error {
+	var nodeProps, edgeProps []map[string]interface{}
+	for _, n := range nodes {
+		nodeProps = append(nodeProps, nodeProperties(n))
+	}
+	for _, r := range relationships {
+		p := relationshipProperties(r)
+		p["edge_label"] = edgeLabel(r)
+		edgeProps = append(edgeProps, p)
+	}
+	nodeAttrs, edgeAttrs := attributes(nodeProps), attributes(edgeProps)
+
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: "topology", EdgeDefault: "directed"},
+	}
+	for i, a := range nodeAttrs {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: fmt.Sprintf("n%d", i), For: "node", Name: a.name, Type: a.kind})
+	}
+	for i, a := range edgeAttrs {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: fmt.Sprintf("e%d", i), For: "edge", Name: a.name, Type: a.kind})
+	}
+
+	for i, n := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID:   nodeID(n.Ref()),
+			Data: graphMLValues("n", nodeAttrs, nodeProps[i]),
+		})
+	}
+	for i, r := range relationships {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: nodeID(r.From),
+			Target: nodeID(r.To),
+			Data:   graphMLValues("e", edgeAttrs, edgeProps[i]),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func graphMLValues(prefix string, attrs []attribute, properties map[string]interface{}) []graphMLData {
+	var data []graphMLData
+	for i, a := range attrs {
+		if v, ok := properties[a.name]; ok && v != nil {
+			data = append(data, graphMLData{Key: fmt.Sprintf("%s%d", prefix, i), Value: value(v)})
+		}
+	}
+	return data
+}
@@ -0,0 +1,160 @@
+package graphSink
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kkirsche/trace2neo/cypherBuilder"
+)
+
+const (
+	// DefaultBatchSize is the number of rows written per transaction
+	DefaultBatchSize = 1000
+	// retries is how many times a transaction failing with a transient error,
+	// such as a deadlock, is retried
+	retries = 5
+)
+
+// batcher buffers upserts as rows grouped by the statement which writes them,
+// so a sink can write a whole group with a single UNWIND. Rows are handed to
+// write once size of them are pending, in the order their groups were first
+// used.
+type batcher struct {
+	size  int
+	write func(groups []*batchGroup) error
+	// inBatch is set while Batch runs, so its writes are flushed together
+	inBatch bool
+	pending int
+	groups  []*batchGroup
+	byQuery map[string]*batchGroup
+}
+
+// batchGroup is the rows waiting to be written with the same statement
+type batchGroup struct {
+	query string
+	rows  []interface{}
+}
+
+func newBatcher(size int, write func(groups []*batchGroup) error) *batcher {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	return &batcher{
+		size:    size,
+		write:   write,
+		byQuery: make(map[string]*batchGroup),
+	}
+}
+
+func (b *batcher) UpsertNode(n Node) error {
+	return b.add(cypherBuilder.MergeNode(n.Label, sortedKeys(n.Key)), map[string]interface{}{
+		"key":   n.Key,
+		"props": nonNil(n.Properties),
+		"seen":  seenAt(n.Seen),
+	})
+}
+
+func (b *batcher) UpsertRelationship(r Relationship) error {
+	query := cypherBuilder.MergeRelationship(r.From.Label, sortedKeys(r.From.Key), r.Type, sortedKeys(r.Key), r.To.Label, sortedKeys(r.To.Key))
+	return b.add(query, map[string]interface{}{
+		"from":  r.From.Key,
+		"to":    r.To.Key,
+		"key":   nonNil(r.Key),
+		"props": nonNil(r.Properties),
+		"seen":  seenAt(r.Seen),
+	})
+}
+
+// Batch runs fn, writing everything it upserts in the same transaction. If fn
+// fails, its writes are discarded.
+func (b *batcher) Batch(fn func() error) error {
+	if b.inBatch {
+		return fn()
+	}
+
+	// remember where every group ended, so the writes made by fn can be undone
+	lengths := make(map[*batchGroup]int, len(b.groups))
+	for _, group := range b.groups {
+		lengths[group] = len(group.rows)
+	}
+	groups, pending := len(b.groups), b.pending
+
+	b.inBatch = true
+	err := fn()
+	b.inBatch = false
+	if err != nil {
+		for _, group := range b.groups[groups:] {
+			delete(b.byQuery, group.query)
+		}
+		b.groups = b.groups[:groups]
+		for _, group := range b.groups {
+			group.rows = group.rows[:lengths[group]]
+		}
+		b.pending = pending
+		return err
+	}
+
+	if b.pending >= b.size {
+		return b.Flush()
+	}
+	return nil
+}
+
+// Flush writes every pending row
+func (b *batcher) Flush() error {
+	if b.pending == 0 {
+		return nil
+	}
+	if err := b.write(b.groups); err != nil {
+		return err
+	}
+
+	b.groups = nil
+	b.byQuery = make(map[string]*batchGroup)
+	b.pending = 0
+	return nil
+}
+
+func (b *batcher) add(query string, row map[string]interface{}) error {
+	group, ok := b.byQuery[query]
+	if !ok {
+		group = &batchGroup{query: query}
+		b.byQuery[query] = group
+		b.groups = append(b.groups, group)
+	}
+	group.rows = append(group.rows, row)
+	b.pending++
+
+	if !b.inBatch && b.pending >= b.size {
+		return b.Flush()
+	}
+	return nil
+}
+
+// retryTransient runs tx, retrying it with exponential backoff while it fails
+// with a transient error
+func retryTransient(tx func() error) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * 100 * time.Millisecond)
+		}
+		if err = tx(); err == nil || !isTransient(err) {
+			break
+		}
+	}
+	return err
+}
+
+// isTransient reports whether err is a Neo4j transient error, which succeeds
+// if the transaction is retried
+func isTransient(err error) bool {
+	return strings.Contains(err.Error(), "Neo.TransientError")
+}
+
+func nonNil(properties map[string]interface{}) map[string]interface{} {
+	if properties == nil {
+		return map[string]interface{}{}
+	}
+	return properties
+}
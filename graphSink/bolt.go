@@ -0,0 +1,62 @@
+package graphSink
+
+import (
+	"context"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// BoltSink writes to Neo4j over Bolt. Upserts are buffered and written a batch
+// at a time, each batch as one transaction of UNWIND statements, one statement
+// per label or relationship type.
+type BoltSink struct {
+	*batcher
+	driver  neo4j.DriverWithContext
+	session neo4j.SessionWithContext
+}
+
+// NewBoltSink creates a sink writing to database, or the default database if
+// it is empty, in transactions of batchSize rows, or DefaultBatchSize if
+// batchSize is not positive. Closing the sink closes driver.
+func NewBoltSink(driver neo4j.DriverWithContext, database string, batchSize int) *BoltSink {
+	b := &BoltSink{
+		driver: driver,
+		session: driver.NewSession(context.Background(), neo4j.SessionConfig{
+			AccessMode:   neo4j.AccessModeWrite,
+			DatabaseName: database,
+		}),
+	}
+	b.batcher = newBatcher(batchSize, b.write)
+	return b
+}
+
+func (b *BoltSink) Close() error {
+	ctx := context.Background()
+	err := b.Flush()
+	if closeErr := b.session.Close(ctx); err == nil {
+		err = closeErr
+	}
+	if closeErr := b.driver.Close(ctx); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// write writes groups in a single transaction. The driver retries it if it
+// fails with a transient error, or in a cluster because the leader changed.
+func (b *BoltSink) write(groups []*batchGroup) error {
+	ctx := context.Background()
+	_, err := b.session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		for _, group := range groups {
+			result, err := tx.Run(ctx, "UNWIND $rows AS row\n"+group.query, map[string]interface{}{"rows": group.rows})
+			if err != nil {
+				return nil, err
+			}
+			if _, err = result.Consume(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
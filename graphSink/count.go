@@ -0,0 +1,41 @@
+package graphSink
+
+// CountingSink counts the node and relationship upserts made through it to the
+// sink it wraps. Upserts of a Batch which fails are not counted. As every
+// upsert is a MERGE, upserting an existing node or relationship again counts
+// again, so these are not the number of nodes and relationships created.
+type CountingSink struct {
+	GraphSink
+	NodeUpserts         int
+	RelationshipUpserts int
+}
+
+// NewCountingSink wraps s
+func NewCountingSink(s GraphSink) *CountingSink {
+	return &CountingSink{GraphSink: s}
+}
+
+func (c *CountingSink) UpsertNode(n Node) error {
+	err := c.GraphSink.UpsertNode(n)
+	if err == nil {
+		c.NodeUpserts++
+	}
+	return err
+}
+
+func (c *CountingSink) UpsertRelationship(r Relationship) error {
+	err := c.GraphSink.UpsertRelationship(r)
+	if err == nil {
+		c.RelationshipUpserts++
+	}
+	return err
+}
+
+func (c *CountingSink) Batch(fn func() error) error {
+	nodes, relationships := c.NodeUpserts, c.RelationshipUpserts
+	err := c.GraphSink.Batch(fn)
+	if err != nil {
+		c.NodeUpserts, c.RelationshipUpserts = nodes, relationships
+	}
+	return err
+}
@@ -0,0 +1,287 @@
+package graphSink
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSVSink collects the graph in memory and, when closed, writes it as the
+// node and relationship CSV files read by neo4j-admin database import, along
+// with an import.sh running the import. Bulk importing bypasses Cypher
+// entirely, which makes it the fastest way to load a very large scan into an
+// empty database.
+type CSVSink struct {
+	*MemorySink
+	dir      string
+	database string
+}
+
+// NewCSVSink creates a sink writing its CSV files to dir, to be imported into
+// database, or neo4j-admin's default database if it is empty
+func NewCSVSink(dir, database string) (*CSVSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &CSVSink{MemorySink: NewMemorySink(), dir: dir, database: database}, nil
+}
+
+// Close writes the CSV files and import.sh
+func (c *CSVSink) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var nodeFiles, relationshipFiles []string
+
+	byLabel := make(map[string][]*Node)
+	for _, id := range sortedNodeIDs(c.nodes) {
+		n := c.nodes[id]
+		byLabel[n.Label] = append(byLabel[n.Label], n)
+	}
+	for _, label := range sortedLabels(byLabel) {
+		fp := filepath.Join(c.dir, label+".csv")
+		if err := writeNodesCSV(fp, label, byLabel[label]); err != nil {
+			return err
+		}
+		nodeFiles = append(nodeFiles, fp)
+	}
+
+	byFile := make(map[string][]*Relationship)
+	var names []string
+	for _, id := range c.order {
+		r := c.relationships[id]
+		name := r.From.Label + "_" + r.Type + "_" + r.To.Label
+		if _, ok := byFile[name]; !ok {
+			names = append(names, name)
+		}
+		byFile[name] = append(byFile[name], r)
+	}
+	for _, name := range names {
+		fp := filepath.Join(c.dir, name+".csv")
+		if err := writeRelationshipsCSV(fp, byFile[name]); err != nil {
+			return err
+		}
+		relationshipFiles = append(relationshipFiles, fp)
+	}
+
+	script := "#!/bin/sh\n" + ImportCommand(c.database, nodeFiles, relationshipFiles) + "\n"
+	return os.WriteFile(filepath.Join(c.dir, "import.sh"), []byte(script), 0755)
+}
+
+// ImportCommand returns the neo4j-admin command line importing the CSV files
+// into database, or neo4j-admin's default database if it is empty. The import
+// fails rather than replace a database which already exists.
+func ImportCommand(database string, nodeFiles, relationshipFiles []string) string {
+	args := []string{"neo4j-admin", "database", "import", "full"}
+	for _, fp := range nodeFiles {
+		args = append(args, "--nodes="+shellQuote(fp))
+	}
+	for _, fp := range relationshipFiles {
+		args = append(args, "--relationships="+shellQuote(fp))
+	}
+	if database != "" {
+		args = append(args, shellQuote(database))
+	}
+	return strings.Join(args, " ")
+}
+
+// writeNodesCSV writes nodes, which all have label, with their key as the ID
+// of the label's ID space
+func writeNodesCSV(fp, label string, nodes []*Node) error {
+	keys := make(map[string]interface{})
+	properties := make(map[string]interface{})
+	for _, n := range nodes {
+		mergeColumns(keys, n.Key)
+		mergeColumns(properties, n.Properties)
+	}
+	keyColumns := sortedKeys(keys)
+	propertyColumns := sortedKeys(properties)
+
+	// a single key property is the ID itself, otherwise the ID combines them
+	idHeader := fmt.Sprintf("id:ID(%s)", label)
+	if len(keyColumns) == 1 {
+		idHeader = fmt.Sprintf("%s:ID(%s)", keyColumns[0], label)
+		keyColumns = nil
+	}
+
+	header := []string{idHeader}
+	header = append(header, typedHeaders(keyColumns, keys)...)
+	header = append(header, typedHeaders(propertyColumns, properties)...)
+	header = append(header, ":LABEL")
+
+	return writeCSV(fp, header, len(nodes), func(i int) []string {
+		n := nodes[i]
+		record := []string{nodeID(n.Key)}
+		record = append(record, csvValues(keyColumns, n.Key)...)
+		record = append(record, csvValues(propertyColumns, n.Properties)...)
+		return append(record, n.Label)
+	})
+}
+
+// writeRelationshipsCSV writes relationships, which all have the same type
+// and labels at either end. Their keys are written as properties.
+func writeRelationshipsCSV(fp string, relationships []*Relationship) error {
+	from, to := relationships[0].From.Label, relationships[0].To.Label
+
+	properties := make(map[string]interface{})
+	for _, r := range relationships {
+		mergeColumns(properties, r.Key)
+		mergeColumns(properties, r.Properties)
+	}
+	columns := sortedKeys(properties)
+
+	header := []string{fmt.Sprintf(":START_ID(%s)", from), fmt.Sprintf(":END_ID(%s)", to)}
+	header = append(header, typedHeaders(columns, properties)...)
+	header = append(header, ":TYPE")
+
+	return writeCSV(fp, header, len(relationships), func(i int) []string {
+		r := relationships[i]
+		values := make(map[string]interface{}, len(r.Key)+len(r.Properties))
+		for k, v := range r.Properties {
+			values[k] = v
+		}
+		for k, v := range r.Key {
+			values[k] = v
+		}
+
+		record := []string{nodeID(r.From.Key), nodeID(r.To.Key)}
+		record = append(record, csvValues(columns, values)...)
+		return append(record, r.Type)
+	})
+}
+
+func writeCSV(fp string, header []string, n int, record func(i int) []string) error {
+	f, err := os.Create(fp)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	err = w.Write(header)
+	for i := 0; i < n && err == nil; i++ {
+		err = w.Write(record(i))
+	}
+	if err == nil {
+		w.Flush()
+		err = w.Error()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// mergeColumns records a value of every property in properties, keeping the
+// first non-nil value seen, and the first non-empty list, so the column type
+// can be worked out
+func mergeColumns(columns, properties map[string]interface{}) {
+	for k, v := range properties {
+		if columns[k] == nil || isEmptyList(columns[k]) {
+			columns[k] = v
+		}
+	}
+}
+
+func isEmptyList(v interface{}) bool {
+	switch v := v.(type) {
+	case []string:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func typedHeaders(columns []string, values map[string]interface{}) []string {
+	var headers []string
+	for _, column := range columns {
+		headers = append(headers, column+":"+csvType(values[column]))
+	}
+	return headers
+}
+
+// csvType returns the neo4j-admin import type of a property value
+func csvType(v interface{}) string {
+	switch v := v.(type) {
+	case int, int64, uint8, uint16, uint32:
+		return "long"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		// lists hold a single type, so the first item stands for the rest
+		if len(v) > 0 && csvType(v[0]) != "string[]" {
+			return csvType(v[0]) + "[]"
+		}
+		return "string[]"
+	case []string:
+		return "string[]"
+	default:
+		return "string"
+	}
+}
+
+func csvValues(columns []string, values map[string]interface{}) []string {
+	var record []string
+	for _, column := range columns {
+		record = append(record, csvValue(values[column]))
+	}
+	return record
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ";")
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ";")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// nodeID returns the ID of a node within its label's ID space
+func nodeID(key map[string]interface{}) string {
+	if len(key) == 1 {
+		for _, v := range key {
+			return fmt.Sprint(v)
+		}
+	}
+	return mapID(key)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func sortedNodeIDs(nodes map[string]*Node) []string {
+	var ids []string
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedLabels(byLabel map[string][]*Node) []string {
+	var labels []string
+	for label := range byLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
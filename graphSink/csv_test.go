@@ -0,0 +1,113 @@
+package graphSink
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/kkirsche/trace2neo/traceroute"
+)
+
+func readCSV(t *testing.T, fp string) [][]string {
+	f, err := os.Open(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestCSVSinkColumnTypes(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewCSVSink(dir, "topology")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probed := time.Unix(1500000000, 0)
+	assets := []*trace2neolib.Asset{
+		// the first asset has no responding ports, so the column type has to
+		// come from a later one
+		{IPAddr: "10.0.0.1", Liveness: &traceroute.Liveness{ProbedAt: probed}},
+		{IPAddr: "10.0.0.2", Liveness: &traceroute.Liveness{Alive: true, Ports: []int{22, 443}, ProbedAt: probed}},
+		{Name: "www.example.com", IPAddr: "10.0.0.3", ForwardIPs: []string{"10.0.0.3"}, FCrDNS: trace2neolib.FCrDNSConfirmed},
+	}
+	for _, asset := range assets {
+		if err = WriteAsset(s, asset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	interfaces := readCSV(t, filepath.Join(dir, "Interface.csv"))
+	wantHeader := []string{
+		"ip:ID(Interface)", "alive:boolean", "echo_reply:boolean", "first_seen:long", "last_alive:long",
+		"last_seen:long", "probed_at:long", "responding_ports:long[]", ":LABEL",
+	}
+	if !reflect.DeepEqual(interfaces[0], wantHeader) {
+		t.Errorf("Got Interface header %v, want %v", interfaces[0], wantHeader)
+	}
+	for _, record := range interfaces[1:] {
+		if record[0] == "10.0.0.2" && record[7] != "22;443" {
+			t.Errorf("Got responding ports %q for 10.0.0.2, want 22;443", record[7])
+		}
+	}
+
+	hosts := readCSV(t, filepath.Join(dir, "Host.csv"))
+	if want := "forward_ips:string[]"; !strings.Contains(strings.Join(hosts[0], ","), want) {
+		t.Errorf("Got Host header %v, want a %s column", hosts[0], want)
+	}
+
+	script, err := os.ReadFile(filepath.Join(dir, "import.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(script), "--overwrite-destination") {
+		t.Errorf("Got import.sh %q, which overwrites the database", script)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(script)), " 'topology'") {
+		t.Errorf("Got import.sh %q, want it to import into topology", script)
+	}
+}
+
+func TestImportCommand(t *testing.T) {
+	got := ImportCommand("", []string{"out/Host.csv"}, []string{"it's/Host_HAS_INTERFACE_Interface.csv"})
+	want := `neo4j-admin database import full --nodes='out/Host.csv' --relationships='it'\''s/Host_HAS_INTERFACE_Interface.csv'`
+	if got != want {
+		t.Errorf("Got %s, want %s", got, want)
+	}
+}
+
+func TestWriteCSVError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("No /dev/full to fail writes with")
+	}
+
+	written := 0
+	record := func(i int) []string {
+		written++
+		return []string{strings.Repeat("x", 8192)}
+	}
+	if err := writeCSV("/dev/full", []string{"name:ID"}, 100, record); err == nil {
+		t.Errorf("Got nil error writing to a full device, want one")
+	}
+	if written == 100 {
+		t.Errorf("Got every record written after the device filled up, want writing to stop")
+	}
+
+	if err := writeCSV("/dev/full", []string{"name:ID"}, 0, record); err == nil {
+		t.Errorf("Got nil error writing only the header to a full device, want one")
+	}
+}
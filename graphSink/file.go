@@ -0,0 +1,84 @@
+package graphSink
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/kkirsche/trace2neo/cypherBuilder"
+)
+
+// FileSink writes the graph as a cypher-shell script. Every batch is wrapped
+// in :begin and :commit and holds one UNWIND statement per label or
+// relationship type, so even scripts for large ranges are made of statements
+// of a manageable size. Every statement MERGEs, so the script can be run
+// repeatedly against the same database.
+type FileSink struct {
+	*batcher
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewFileSink creates a sink writing a script to fp, replacing any existing
+// file, in batches of batchSize rows or DefaultBatchSize if batchSize is not
+// positive
+func NewFileSink(fp string, batchSize int) (*FileSink, error) {
+	f, err := os.Create(fp)
+	if err != nil {
+		return nil, err
+	}
+	return newFileSink(f, batchSize), nil
+}
+
+// NewAppendFileSink creates a sink like NewFileSink, except that it appends to
+// any existing script, as when resuming an interrupted run
+func NewAppendFileSink(fp string, batchSize int) (*FileSink, error) {
+	f, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return newFileSink(f, batchSize), nil
+}
+
+func newFileSink(f *os.File, batchSize int) *FileSink {
+	s := &FileSink{f: f, w: bufio.NewWriter(f)}
+	s.batcher = newBatcher(batchSize, s.write)
+	return s
+}
+
+// Flush writes any buffered batches through to the file
+func (f *FileSink) Flush() error {
+	if err := f.batcher.Flush(); err != nil {
+		return err
+	}
+	return f.w.Flush()
+}
+
+func (f *FileSink) Close() error {
+	err := f.Flush()
+	if closeErr := f.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (f *FileSink) write(groups []*batchGroup) error {
+	f.w.WriteString(":begin\n")
+	for _, group := range groups {
+		f.w.WriteString("UNWIND [\n")
+		for i, row := range group.rows {
+			f.w.WriteString("  ")
+			f.w.WriteString(cypherBuilder.Literal(row))
+			if i < len(group.rows)-1 {
+				f.w.WriteString(",")
+			}
+			f.w.WriteString("\n")
+		}
+		f.w.WriteString("] AS row\n")
+		f.w.WriteString(group.query)
+		f.w.WriteString(";\n")
+	}
+	// errors of a bufio.Writer are sticky: once a write fails every later one
+	// returns the same error, so checking the last write checks them all
+	_, err := f.w.WriteString(":commit\n")
+	return err
+}
@@ -0,0 +1,30 @@
+package graphSink
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/kkirsche/trace2neo/trace2neolib"
+)
+
+func TestFileSinkWriteError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("No /dev/full to fail writes with")
+	}
+
+	s, err := NewFileSink("/dev/full", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first batch fills the buffer, so a write in the middle of it fails
+	for i := 0; i < 100; i++ {
+		if err = WriteAsset(s, &trace2neolib.Asset{IPAddr: fmt.Sprintf("10.0.0.%d", i)}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		t.Errorf("Got nil error writing a batch to a full device, want one")
+	}
+	s.f.Close()
+}
@@ -0,0 +1,218 @@
+package graphSink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// HTTPSink writes to Neo4j through its HTTP transactional Cypher API, for
+// databases which are only reachable over HTTP(S). Like BoltSink, upserts are
+// buffered and written a batch at a time, each batch as one transaction of
+// UNWIND statements.
+type HTTPSink struct {
+	*batcher
+	client             *http.Client
+	baseURL, database  string
+	username, password string
+	// url is the transaction endpoint of database, found on the first write
+	url string
+}
+
+// Neo4jError is an error reported by Neo4j, such as
+// Neo.ClientError.Statement.SyntaxError
+type Neo4jError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Neo4jError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+type httpStatement struct {
+	Statement  string                 `json:"statement"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type httpRequest struct {
+	Statements []httpStatement `json:"statements"`
+}
+
+type httpResponse struct {
+	Commit  string `json:"commit"`
+	Results []struct {
+		Data []struct {
+			Row []interface{} `json:"row"`
+		} `json:"data"`
+	} `json:"results"`
+	Errors []*Neo4jError `json:"errors"`
+}
+
+// NewHTTPSink creates a sink writing to the database named database of the
+// Neo4j server at baseURL, such as https://neo4j.example.com:7473. If database
+// is empty the user's home database is used, which is the server's default
+// database unless the user has been given another.
+func NewHTTPSink(baseURL, database, username, password string, batchSize int) *HTTPSink {
+	h := &HTTPSink{
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		database: database,
+		username: username,
+		password: password,
+	}
+	h.batcher = newBatcher(batchSize, h.write)
+	return h
+}
+
+func (h *HTTPSink) Close() error {
+	return h.Flush()
+}
+
+// write writes groups in a single transaction, retrying it if it fails with a
+// transient error
+func (h *HTTPSink) write(groups []*batchGroup) error {
+	var statements []httpStatement
+	for _, group := range groups {
+		statements = append(statements, httpStatement{
+			Statement:  "UNWIND $rows AS row\n" + group.query,
+			Parameters: map[string]interface{}{"rows": group.rows},
+		})
+	}
+
+	return retryTransient(func() error {
+		return h.writeTx(statements)
+	})
+}
+
+// writeTx begins a transaction running statements and commits it. Neo4j rolls
+// the transaction back itself if any statement fails, but not if the commit
+// never reaches it, so writeTx then rolls it back.
+func (h *HTTPSink) writeTx(statements []httpStatement) error {
+	url, err := h.txURL()
+	if err != nil {
+		return err
+	}
+
+	begun, err := h.post(url, statements)
+	if err != nil {
+		return err
+	}
+	if begun.Commit == "" {
+		return fmt.Errorf("Neo4j did not return a commit URL for the transaction")
+	}
+	if err = h.checkSameServer(begun.Commit); err == nil {
+		_, err = h.post(begun.Commit, []httpStatement{})
+	}
+	if err != nil {
+		h.rollback(url, begun.Commit)
+	}
+	return err
+}
+
+// rollback rolls back the transaction committed by commitURL, so that it does
+// not hold its locks until it times out. The transaction URL is built from the
+// transaction endpoint url, as commitURL may be on another server. Errors are
+// ignored: the transaction may already be gone, and times out otherwise.
+func (h *HTTPSink) rollback(url, commitURL string) {
+	u, err := neturl.Parse(commitURL)
+	if err != nil {
+		return
+	}
+	id := path.Base(strings.TrimSuffix(u.Path, "/commit"))
+	if id == "." || id == "/" {
+		return
+	}
+
+	req, err := http.NewRequest("DELETE", url+"/"+neturl.PathEscape(id), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json;charset=UTF-8")
+	req.SetBasicAuth(h.username, h.password)
+	if resp, err := h.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// txURL returns the transaction endpoint of the database, looking up the home
+// database of the user if no database was given
+func (h *HTTPSink) txURL() (string, error) {
+	if h.url != "" {
+		return h.url, nil
+	}
+
+	database := h.database
+	if database == "" {
+		home, err := h.post(h.baseURL+"/db/system/tx/commit", []httpStatement{{Statement: "SHOW HOME DATABASE YIELD name"}})
+		if err != nil {
+			return "", fmt.Errorf("Failed to look up the home database: %s", err)
+		}
+		if len(home.Results) == 0 || len(home.Results[0].Data) == 0 || len(home.Results[0].Data[0].Row) == 0 {
+			return "", fmt.Errorf("Neo4j did not return a home database")
+		}
+		name, ok := home.Results[0].Data[0].Row[0].(string)
+		if !ok || name == "" {
+			return "", fmt.Errorf("Neo4j did not return a home database")
+		}
+		database = name
+	}
+
+	h.url = h.baseURL + "/db/" + neturl.PathEscape(database) + "/tx"
+	return h.url, nil
+}
+
+// checkSameServer makes sure a URL returned by Neo4j is on the server the sink
+// was configured with, so that credentials are never sent elsewhere
+func (h *HTTPSink) checkSameServer(url string) error {
+	base, err := neturl.Parse(h.baseURL)
+	if err != nil {
+		return err
+	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return fmt.Errorf("Neo4j returned an invalid commit URL %q: %s", url, err)
+	}
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return fmt.Errorf("Neo4j returned a commit URL %s on another server than %s", url, h.baseURL)
+	}
+	return nil
+}
+
+func (h *HTTPSink) post(url string, statements []httpStatement) (*httpResponse, error) {
+	body, err := json.Marshal(httpRequest{Statements: statements})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json;charset=UTF-8")
+	req.SetBasicAuth(h.username, h.password)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result httpResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("Failed to decode Neo4j response: %s", err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, result.Errors[0]
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Neo4j returned %s", resp.Status)
+	}
+	return &result, nil
+}
@@ -0,0 +1,253 @@
+package graphSink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kkirsche/trace2neo/trace2neolib"
+)
+
+// fakeNeo4j stands in for the HTTP transactional API of Neo4j. begin answers
+// each request to begin a transaction on /db/{db}/tx, and is given the
+// statements and the number of the attempt. With dropCommit, requests to commit
+// a transaction are dropped without an answer.
+type fakeNeo4j struct {
+	*httptest.Server
+	t          *testing.T
+	begin      func(db string, req httpRequest, attempt int) string
+	dropCommit bool
+
+	mu        sync.Mutex
+	begins    []string
+	commits   int
+	rollbacks []string
+	home      int
+}
+
+func newFakeNeo4j(t *testing.T, begin func(db string, req httpRequest, attempt int) string) *fakeNeo4j {
+	f := &fakeNeo4j{t: t, begin: begin}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeNeo4j) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user, password, _ := r.BasicAuth(); user != "neo4j" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"errors":[{"code":"Neo.ClientError.Security.Unauthorized","message":"Invalid username or password."}]}`)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method == "DELETE" {
+		if len(parts) != 4 || parts[2] != "tx" {
+			f.t.Errorf("Unexpected DELETE of %s", r.URL.Path)
+		}
+		f.rollbacks = append(f.rollbacks, r.URL.Path)
+		fmt.Fprint(w, `{"results":[],"errors":[]}`)
+		return
+	}
+
+	var req httpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		f.t.Errorf("Failed to decode request to %s: %s", r.URL.Path, err)
+	}
+
+	switch {
+	case r.URL.Path == "/db/system/tx/commit":
+		f.home++
+		if len(req.Statements) != 1 || req.Statements[0].Statement != "SHOW HOME DATABASE YIELD name" {
+			f.t.Errorf("Got statements %+v for the system database", req.Statements)
+		}
+		fmt.Fprint(w, `{"results":[{"columns":["name"],"data":[{"row":["graph"]}]}],"errors":[]}`)
+	case len(parts) == 3 && parts[0] == "db" && parts[2] == "tx":
+		f.begins = append(f.begins, parts[1])
+		body := f.begin(parts[1], req, len(f.begins))
+		if strings.Contains(body, `"commit"`) {
+			w.WriteHeader(http.StatusCreated)
+		}
+		fmt.Fprint(w, body)
+	case len(parts) == 5 && parts[2] == "tx" && parts[4] == "commit":
+		if f.dropCommit {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				f.t.Fatal(err)
+			}
+			conn.Close()
+			return
+		}
+		f.commits++
+		if len(req.Statements) != 0 {
+			f.t.Errorf("Got statements %+v committing the transaction", req.Statements)
+		}
+		fmt.Fprint(w, `{"results":[],"errors":[]}`)
+	default:
+		f.t.Errorf("Unexpected request to %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// committed answers a request to begin a transaction with its commit URL
+func (f *fakeNeo4j) committed(db string) string {
+	return fmt.Sprintf(`{"commit":"%s/db/%s/tx/1/commit","results":[],"errors":[]}`, f.URL, db)
+}
+
+func writeAssets(t *testing.T, s GraphSink, n int) error {
+	for i := 0; i < n; i++ {
+		if err := WriteAsset(s, &trace2neolib.Asset{IPAddr: fmt.Sprintf("10.0.0.%d", i)}); err != nil {
+			return err
+		}
+	}
+	return s.Close()
+}
+
+func TestHTTPSinkCommit(t *testing.T) {
+	var f *fakeNeo4j
+	f = newFakeNeo4j(t, func(db string, req httpRequest, attempt int) string {
+		for _, statement := range req.Statements {
+			if !strings.HasPrefix(statement.Statement, "UNWIND $rows AS row\nMERGE") {
+				t.Errorf("Got statement %q, want an UNWIND of MERGEs", statement.Statement)
+			}
+			if _, ok := statement.Parameters["rows"]; !ok {
+				t.Errorf("Got parameters %v, want rows", statement.Parameters)
+			}
+		}
+		return f.committed(db)
+	})
+	defer f.Close()
+
+	if err := writeAssets(t, NewHTTPSink(f.URL+"/", "topology", "neo4j", "secret", 2), 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.begins) != 3 || f.commits != 3 {
+		t.Errorf("Got %d transactions begun and %d committed, want 3 and 3", len(f.begins), f.commits)
+	}
+	for _, db := range f.begins {
+		if db != "topology" {
+			t.Errorf("Got a transaction on %s, want topology", db)
+		}
+	}
+	if f.home != 0 {
+		t.Errorf("Looked up the home database %d times when a database was given", f.home)
+	}
+}
+
+func TestHTTPSinkHomeDatabase(t *testing.T) {
+	var f *fakeNeo4j
+	f = newFakeNeo4j(t, func(db string, req httpRequest, attempt int) string {
+		return f.committed(db)
+	})
+	defer f.Close()
+
+	if err := writeAssets(t, NewHTTPSink(f.URL, "", "neo4j", "secret", 1), 2); err != nil {
+		t.Fatal(err)
+	}
+	if f.home != 1 {
+		t.Errorf("Looked up the home database %d times, want once", f.home)
+	}
+	for _, db := range f.begins {
+		if db != "graph" {
+			t.Errorf("Got a transaction on %s, want the home database graph", db)
+		}
+	}
+}
+
+func TestHTTPSinkNeo4jError(t *testing.T) {
+	f := newFakeNeo4j(t, func(db string, req httpRequest, attempt int) string {
+		return `{"results":[],"errors":[{"code":"Neo.ClientError.Schema.ConstraintValidationFailed","message":"Node already exists"}]}`
+	})
+	defer f.Close()
+
+	err := writeAssets(t, NewHTTPSink(f.URL, "neo4j", "neo4j", "secret", 10), 1)
+	neo4jErr, ok := err.(*Neo4jError)
+	if !ok || neo4jErr.Code != "Neo.ClientError.Schema.ConstraintValidationFailed" {
+		t.Fatalf("Got error %v, want the Neo4jError", err)
+	}
+	if len(f.begins) != 1 || f.commits != 0 {
+		t.Errorf("Got %d attempts and %d commits, want one attempt which is not retried", len(f.begins), f.commits)
+	}
+
+	err = writeAssets(t, NewHTTPSink(f.URL, "neo4j", "neo4j", "wrong", 10), 1)
+	if neo4jErr, ok = err.(*Neo4jError); !ok || neo4jErr.Code != "Neo.ClientError.Security.Unauthorized" {
+		t.Errorf("Got error %v with the wrong password, want Unauthorized", err)
+	}
+}
+
+func TestHTTPSinkRetriesTransient(t *testing.T) {
+	var f *fakeNeo4j
+	f = newFakeNeo4j(t, func(db string, req httpRequest, attempt int) string {
+		if attempt < 3 {
+			return `{"results":[],"errors":[{"code":"Neo.TransientError.Transaction.DeadlockDetected","message":"Deadlock"}]}`
+		}
+		return f.committed(db)
+	})
+	defer f.Close()
+
+	if err := writeAssets(t, NewHTTPSink(f.URL, "neo4j", "neo4j", "secret", 10), 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.begins) != 3 || f.commits != 1 {
+		t.Errorf("Got %d attempts and %d commits, want 3 and 1", len(f.begins), f.commits)
+	}
+}
+
+func TestHTTPSinkForeignCommitURL(t *testing.T) {
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Commit was posted to another server: %s", r.URL)
+	}))
+	defer elsewhere.Close()
+
+	f := newFakeNeo4j(t, func(db string, req httpRequest, attempt int) string {
+		return fmt.Sprintf(`{"commit":"%s/db/%s/tx/1/commit","results":[],"errors":[]}`, elsewhere.URL, db)
+	})
+	defer f.Close()
+
+	err := writeAssets(t, NewHTTPSink(f.URL, "neo4j", "neo4j", "secret", 10), 1)
+	if err == nil || !strings.Contains(err.Error(), "another server") {
+		t.Errorf("Got error %v, want the commit URL refused", err)
+	}
+	if len(f.rollbacks) != 1 || f.rollbacks[0] != "/db/neo4j/tx/1" {
+		t.Errorf("Got rollbacks %v, want the transaction rolled back on the configured server", f.rollbacks)
+	}
+}
+
+func TestHTTPSinkRollsBackDroppedCommit(t *testing.T) {
+	var f *fakeNeo4j
+	f = newFakeNeo4j(t, func(db string, req httpRequest, attempt int) string {
+		return f.committed(db)
+	})
+	f.dropCommit = true
+	defer f.Close()
+
+	if err := writeAssets(t, NewHTTPSink(f.URL, "neo4j", "neo4j", "secret", 10), 1); err == nil {
+		t.Error("Got no error when the commit was dropped")
+	}
+	if len(f.rollbacks) != 1 || f.rollbacks[0] != "/db/neo4j/tx/1" {
+		t.Errorf("Got rollbacks %v, want /db/neo4j/tx/1", f.rollbacks)
+	}
+}
+
+func TestHTTPSinkNoRollbackOnCommit(t *testing.T) {
+	var f *fakeNeo4j
+	f = newFakeNeo4j(t, func(db string, req httpRequest, attempt int) string {
+		if attempt == 1 {
+			return `{"results":[],"errors":[{"code":"Neo.ClientError.Statement.SyntaxError","message":"Invalid input"}]}`
+		}
+		return f.committed(db)
+	})
+	defer f.Close()
+
+	writeAssets(t, NewHTTPSink(f.URL, "neo4j", "neo4j", "secret", 10), 1)
+	if err := writeAssets(t, NewHTTPSink(f.URL, "neo4j", "neo4j", "secret", 10), 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.rollbacks) != 0 {
+		t.Errorf("Got rollbacks %v of transactions Neo4j had already ended", f.rollbacks)
+	}
+}
@@ -0,0 +1,210 @@
+package graphSink
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemorySink keeps the graph in memory, which makes it possible to inspect
+// what would be written without a running Neo4j
+type MemorySink struct {
+	mu            sync.Mutex
+	nodes         map[string]*Node
+	relationships map[string]*Relationship
+	order         []string
+	// batches is how many batches are running, and undo reverts each write
+	// made since the outermost one began
+	batches int
+	undo    []func()
+}
+
+// NewMemorySink creates an empty in-memory graph
+func NewMemorySink() *MemorySink {
+	return &MemorySink{
+		nodes:         make(map[string]*Node),
+		relationships: make(map[string]*Relationship),
+	}
+}
+
+func (m *MemorySink) UpsertNode(n Node) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.upsertNode(n)
+	return nil
+}
+
+func (m *MemorySink) upsertNode(n Node) {
+	id := n.Ref().ID()
+	existing, ok := m.nodes[id]
+	if !ok {
+		existing = &Node{Label: n.Label, Key: n.Key, Properties: make(map[string]interface{})}
+		m.nodes[id] = existing
+		m.record(func() { delete(m.nodes, id) })
+	} else {
+		m.record(restoreProperties(existing.Properties, &existing.Properties))
+	}
+	for key, value := range n.Properties {
+		existing.Properties[key] = value
+	}
+	markSeen(existing.Properties, seenAt(n.Seen))
+}
+
+func (m *MemorySink) UpsertRelationship(r Relationship) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.upsertNode(Node{Label: r.From.Label, Key: r.From.Key, Seen: r.Seen})
+	m.upsertNode(Node{Label: r.To.Label, Key: r.To.Key, Seen: r.Seen})
+
+	id := r.ID()
+	existing, ok := m.relationships[id]
+	if !ok {
+		existing = &Relationship{Type: r.Type, From: r.From, To: r.To, Key: r.Key, Properties: make(map[string]interface{})}
+		m.relationships[id] = existing
+		m.order = append(m.order, id)
+		m.record(func() {
+			delete(m.relationships, id)
+			m.order = m.order[:len(m.order)-1]
+		})
+	} else {
+		m.record(restoreProperties(existing.Properties, &existing.Properties))
+	}
+	for key, value := range r.Properties {
+		existing.Properties[key] = value
+	}
+	markSeen(existing.Properties, seenAt(r.Seen))
+	return nil
+}
+
+// markSeen widens first_seen and last_seen to include seen, as the Neo4j
+// sinks do
+func markSeen(properties map[string]interface{}, seen int64) {
+	if first, ok := properties["first_seen"].(int64); !ok || seen < first {
+		properties["first_seen"] = seen
+	}
+	if last, ok := properties["last_seen"].(int64); !ok || seen > last {
+		properties["last_seen"] = seen
+	}
+}
+
+// record keeps undo to revert a write if a batch is running
+func (m *MemorySink) record(undo func()) {
+	if m.batches > 0 {
+		m.undo = append(m.undo, undo)
+	}
+}
+
+// restoreProperties returns a func setting *dst back to a copy of properties
+// as they are now
+func restoreProperties(properties map[string]interface{}, dst *map[string]interface{}) func() {
+	saved := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		saved[key] = value
+	}
+	return func() { *dst = saved }
+}
+
+// Batch runs fn, reverting every write it made if it fails
+func (m *MemorySink) Batch(fn func() error) error {
+	m.mu.Lock()
+	m.batches++
+	mark := len(m.undo)
+	m.mu.Unlock()
+
+	err := fn()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches--
+	if err != nil {
+		for i := len(m.undo) - 1; i >= mark; i-- {
+			m.undo[i]()
+		}
+		m.undo = m.undo[:mark]
+	}
+	if m.batches == 0 {
+		m.undo = nil
+	}
+	return err
+}
+
+func (m *MemorySink) Flush() error {
+	return nil
+}
+
+func (m *MemorySink) Close() error {
+	return nil
+}
+
+// Node returns the node with the given label and key, if it has been written
+func (m *MemorySink) Node(label string, key map[string]interface{}) (Node, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[NodeRef{Label: label, Key: key}.ID()]
+	if !ok {
+		return Node{}, false
+	}
+	return *n, true
+}
+
+// Nodes returns every node written with the given label
+func (m *MemorySink) Nodes(label string) []Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var nodes []Node
+	for _, n := range m.nodes {
+		if n.Label == label {
+			nodes = append(nodes, *n)
+		}
+	}
+	return nodes
+}
+
+// Relationships returns every relationship written with the given type, in
+// the order they were first written
+func (m *MemorySink) Relationships(relType string) []Relationship {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var relationships []Relationship
+	for _, id := range m.order {
+		if r := m.relationships[id]; r.Type == relType {
+			relationships = append(relationships, *r)
+		}
+	}
+	return relationships
+}
+
+// AllNodes returns every node written, ordered by label and key
+func (m *MemorySink) AllNodes() []Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []string
+	for id := range m.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var nodes []Node
+	for _, id := range ids {
+		nodes = append(nodes, *m.nodes[id])
+	}
+	return nodes
+}
+
+// AllRelationships returns every relationship written, in the order they were
+// first written
+func (m *MemorySink) AllRelationships() []Relationship {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var relationships []Relationship
+	for _, id := range m.order {
+		relationships = append(relationships, *m.relationships[id])
+	}
+	return relationships
+}
@@ -0,0 +1,419 @@
+package graphSink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/kkirsche/trace2neo/traceroute"
+)
+
+func nodeIDs(m *MemorySink) []string {
+	var ids []string
+	for _, n := range m.AllNodes() {
+		ids = append(ids, n.Ref().ID())
+	}
+	return ids
+}
+
+func relationshipIDs(m *MemorySink) []string {
+	var ids []string
+	for _, r := range m.AllRelationships() {
+		ids = append(ids, r.ID())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestMemorySinkUpsertNode(t *testing.T) {
+	m := NewMemorySink()
+	key := map[string]interface{}{"ip": "10.0.0.1"}
+	first := time.Unix(1000, 0)
+
+	writes := []Node{
+		{Label: "Interface", Key: key, Properties: map[string]interface{}{"alive": false}, Seen: first.Add(time.Minute)},
+		{Label: "Interface", Key: key, Properties: map[string]interface{}{"alive": true}, Seen: first},
+		{Label: "Interface", Key: key, Properties: map[string]interface{}{"ping_rtt": 1.5}, Seen: first.Add(time.Hour)},
+	}
+	for _, n := range writes {
+		if err := m.UpsertNode(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if nodes := m.AllNodes(); len(nodes) != 1 {
+		t.Fatalf("Got %d nodes, want 1", len(nodes))
+	}
+	n, ok := m.Node("Interface", key)
+	if !ok {
+		t.Fatal("Interface 10.0.0.1 was not written")
+	}
+	want := map[string]interface{}{
+		"alive":      true,
+		"ping_rtt":   1.5,
+		"first_seen": first.Unix(),
+		"last_seen":  first.Add(time.Hour).Unix(),
+	}
+	if !reflect.DeepEqual(n.Properties, want) {
+		t.Errorf("Got properties %v, want %v", n.Properties, want)
+	}
+}
+
+func TestMemorySinkUpsertRelationship(t *testing.T) {
+	m := NewMemorySink()
+	first := time.Unix(1000, 0)
+	hop := func(seen time.Time, rtt float64) Relationship {
+		return Relationship{
+			Type:       "HOP",
+			From:       interfaceRef("10.0.0.1"),
+			To:         interfaceRef("10.0.0.2"),
+			Key:        map[string]interface{}{"trace_id": "t1", "ttl": 1},
+			Properties: map[string]interface{}{"rtt": rtt},
+			Seen:       seen,
+		}
+	}
+
+	for _, r := range []Relationship{hop(first, 1), hop(first.Add(time.Minute), 2), hop(first.Add(-time.Minute), 3)} {
+		if err := m.UpsertRelationship(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	relationships := m.AllRelationships()
+	if len(relationships) != 1 {
+		t.Fatalf("Got %d relationships, want 1", len(relationships))
+	}
+	want := map[string]interface{}{
+		"rtt":        3.0,
+		"first_seen": first.Add(-time.Minute).Unix(),
+		"last_seen":  first.Add(time.Minute).Unix(),
+	}
+	if got := relationships[0].Properties; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got properties %v, want %v", got, want)
+	}
+
+	wantNodes := []string{"Interface:ip=10.0.0.1;", "Interface:ip=10.0.0.2;"}
+	if got := nodeIDs(m); !reflect.DeepEqual(got, wantNodes) {
+		t.Errorf("Got nodes %v, want %v", got, wantNodes)
+	}
+	if n, _ := m.Node("Interface", map[string]interface{}{"ip": "10.0.0.1"}); n.Properties["first_seen"] != first.Add(-time.Minute).Unix() {
+		t.Errorf("Got first_seen %v for the node, want %d", n.Properties["first_seen"], first.Add(-time.Minute).Unix())
+	}
+}
+
+func TestMemorySinkBatchRollback(t *testing.T) {
+	m := NewMemorySink()
+	seen := time.Unix(1000, 0)
+	existing := Node{Label: "Interface", Key: map[string]interface{}{"ip": "10.0.0.1"}, Properties: map[string]interface{}{"alive": true}, Seen: seen}
+	if err := m.UpsertNode(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("write failed")
+	err := m.Batch(func() error {
+		update := existing
+		update.Properties = map[string]interface{}{"alive": false, "probed_at": 2000}
+		update.Seen = seen.Add(time.Hour)
+		if err := m.UpsertNode(update); err != nil {
+			return err
+		}
+		err := m.Batch(func() error {
+			return m.UpsertRelationship(Relationship{Type: "HAS_INTERFACE", From: hostRef("www.example.com"), To: existing.Ref()})
+		})
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Got error %v, want %v", err, failed)
+	}
+
+	want := map[string]interface{}{"alive": true, "first_seen": seen.Unix(), "last_seen": seen.Unix()}
+	if got := nodeIDs(m); !reflect.DeepEqual(got, []string{"Interface:ip=10.0.0.1;"}) {
+		t.Errorf("Got nodes %v after the batch failed, want only the Interface", got)
+	}
+	if n, _ := m.Node("Interface", existing.Key); !reflect.DeepEqual(n.Properties, want) {
+		t.Errorf("Got properties %v after the batch failed, want %v", n.Properties, want)
+	}
+	if got := m.AllRelationships(); len(got) != 0 {
+		t.Errorf("Got relationships %v after the batch failed, want none", got)
+	}
+
+	// a batch which succeeds keeps its writes
+	err = m.Batch(func() error {
+		return m.UpsertRelationship(Relationship{Type: "HAS_INTERFACE", From: hostRef("www.example.com"), To: existing.Ref()})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.AllRelationships(); len(got) != 1 {
+		t.Errorf("Got %d relationships after the batch succeeded, want 1", len(got))
+	}
+}
+
+func TestWriteTrace(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	trace := trace2neolib.NewTrace(net.ParseIP("10.0.0.3"), "icmp", start)
+	trace.ID = "t1"
+	trace.Source = net.ParseIP("10.0.0.1")
+	trace.TargetName = "target.example.com"
+	trace.Hops = []trace2neolib.Hop{
+		{TTL: 1, Replies: []trace2neolib.Reply{{IP: net.ParseIP("10.0.0.2"), Name: "gw.Example.com.", RTT: 1500 * time.Microsecond}}},
+		{TTL: 2, Replies: []trace2neolib.Reply{{Timeout: true}}},
+		{TTL: 3, Replies: []trace2neolib.Reply{{IP: net.ParseIP("10.0.0.3"), RTT: 3 * time.Millisecond}}},
+	}
+
+	m := NewMemorySink()
+	if err := WriteTrace(m, trace); err != nil {
+		t.Fatal(err)
+	}
+
+	wantNodes := []string{
+		"Domain:name=com;",
+		"Domain:name=example.com;",
+		"Host:name=gw.example.com;",
+		"Host:name=target.example.com;",
+		"Interface:ip=10.0.0.1;",
+		"Interface:ip=10.0.0.2;",
+		"Interface:ip=10.0.0.3;",
+		"Trace:id=t1;",
+	}
+	if got := nodeIDs(m); !reflect.DeepEqual(got, wantNodes) {
+		t.Errorf("Got nodes %v, want %v", got, wantNodes)
+	}
+
+	wantRelationships := []string{
+		"Domain:name=example.com;-IN_DOMAIN:->Domain:name=com;",
+		"Host:name=gw.example.com;-HAS_INTERFACE:->Interface:ip=10.0.0.2;",
+		"Host:name=gw.example.com;-IN_DOMAIN:->Domain:name=example.com;",
+		"Host:name=gw.example.com;-RESOLVES_TO:->Interface:ip=10.0.0.2;",
+		"Host:name=target.example.com;-IN_DOMAIN:->Domain:name=example.com;",
+		"Host:name=target.example.com;-RESOLVES_TO:->Interface:ip=10.0.0.3;",
+		"Interface:ip=10.0.0.1;-HOP:trace_id=t1;ttl=1;->Interface:ip=10.0.0.2;",
+		"Interface:ip=10.0.0.2;-HOP:trace_id=t1;ttl=3;->Interface:ip=10.0.0.3;",
+		"Trace:id=t1;-FROM:->Interface:ip=10.0.0.1;",
+		"Trace:id=t1;-TO:->Interface:ip=10.0.0.3;",
+	}
+	if got := relationshipIDs(m); !reflect.DeepEqual(got, wantRelationships) {
+		t.Errorf("Got relationships %v, want %v", got, wantRelationships)
+	}
+
+	wantResolvesTo := map[string]map[string]interface{}{
+		"gw.example.com 10.0.0.2":     {"ptr": true},
+		"target.example.com 10.0.0.3": {"forward": true},
+	}
+	if got := resolvesTo(m); !reflect.DeepEqual(got, wantResolvesTo) {
+		t.Errorf("Got RESOLVES_TO %v, want %v", got, wantResolvesTo)
+	}
+
+	hops := m.Relationships("HOP")
+	if len(hops) != 2 {
+		t.Fatalf("Got %d hops, want 2", len(hops))
+	}
+	if rtt := hops[0].Properties["rtt"]; rtt != 1.5 {
+		t.Errorf("Got rtt %v for the first hop, want 1.5", rtt)
+	}
+	if seen := hops[1].Properties["first_seen"]; seen != start.Unix() {
+		t.Errorf("Got first_seen %v for the last hop, want %d", seen, start.Unix())
+	}
+	if gap := hops[1].Properties["ttl_gap"]; gap != 2 {
+		t.Errorf("Got ttl_gap %v for the hop bridging a timeout, want 2", gap)
+	}
+	if ambiguous := hops[1].Properties["ambiguous"]; ambiguous != false {
+		t.Errorf("Got ambiguous %v for a single path, want false", ambiguous)
+	}
+}
+
+func TestWriteTraceLoadBalanced(t *testing.T) {
+	trace := trace2neolib.NewTrace(net.ParseIP("10.0.0.9"), "udp", time.Unix(1500000000, 0))
+	trace.ID = "t1"
+	trace.Source = net.ParseIP("10.0.0.1")
+	trace.Hops = []trace2neolib.Hop{
+		{TTL: 1, Replies: []trace2neolib.Reply{
+			{IP: net.ParseIP("10.0.1.1"), Probe: 1},
+			{IP: net.ParseIP("10.0.2.1"), Probe: 2},
+		}},
+		{TTL: 2, Replies: []trace2neolib.Reply{
+			{IP: net.ParseIP("10.0.1.2"), Probe: 1},
+			{IP: net.ParseIP("10.0.2.2"), Probe: 2},
+			{IP: net.ParseIP("10.0.2.2"), Probe: 2},
+		}},
+		{TTL: 3, Replies: []trace2neolib.Reply{{IP: net.ParseIP("10.0.0.9")}}},
+	}
+
+	m := NewMemorySink()
+	if err := WriteTrace(m, trace); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, hop := range m.Relationships("HOP") {
+		got = append(got, fmt.Sprintf("%s->%s ambiguous=%v", hop.From.Key["ip"], hop.To.Key["ip"], hop.Properties["ambiguous"]))
+	}
+	want := []string{
+		"10.0.0.1->10.0.1.1 ambiguous=false",
+		"10.0.0.1->10.0.2.1 ambiguous=false",
+		"10.0.1.1->10.0.1.2 ambiguous=false",
+		"10.0.2.1->10.0.2.2 ambiguous=false",
+		"10.0.1.2->10.0.0.9 ambiguous=true",
+		"10.0.2.2->10.0.0.9 ambiguous=true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got hops %v, want %v", got, want)
+	}
+}
+
+func TestWriteAsset(t *testing.T) {
+	probed := time.Unix(1500000000, 0)
+	assets := []*trace2neolib.Asset{
+		{
+			Name:     "www.example.com.",
+			IPAddr:   "10.0.0.1",
+			Subnet:   "10.0.0.0/24",
+			Liveness: &traceroute.Liveness{Alive: true, Ports: []int{22, 443}, ProbedAt: probed},
+		},
+		{
+			Name:       "old.example.com.",
+			IPAddr:     "10.0.0.1",
+			Subnet:     "10.0.0.0/24",
+			ForwardIPs: []string{"10.0.0.9"},
+			FCrDNS:     trace2neolib.FCrDNSMismatched,
+		},
+		{IPAddr: "10.0.0.2", Subnet: "10.0.0.0/24", Liveness: &traceroute.Liveness{ProbedAt: probed}},
+	}
+
+	m := NewMemorySink()
+	for _, asset := range assets {
+		if err := WriteAsset(m, asset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wantNodes := []string{
+		"Domain:name=com;",
+		"Domain:name=example.com;",
+		"Host:name=old.example.com;",
+		"Host:name=www.example.com;",
+		"Interface:ip=10.0.0.1;",
+		"Interface:ip=10.0.0.2;",
+		"Interface:ip=10.0.0.9;",
+		"Subnet:cidr=10.0.0.0/24;",
+	}
+	if got := nodeIDs(m); !reflect.DeepEqual(got, wantNodes) {
+		t.Errorf("Got nodes %v, want %v", got, wantNodes)
+	}
+
+	wantRelationships := []string{
+		"Domain:name=example.com;-IN_DOMAIN:->Domain:name=com;",
+		"Host:name=old.example.com;-HAS_INTERFACE:->Interface:ip=10.0.0.1;",
+		"Host:name=old.example.com;-IN_DOMAIN:->Domain:name=example.com;",
+		"Host:name=old.example.com;-RESOLVES_TO:->Interface:ip=10.0.0.1;",
+		"Host:name=old.example.com;-RESOLVES_TO:->Interface:ip=10.0.0.9;",
+		"Host:name=www.example.com;-HAS_INTERFACE:->Interface:ip=10.0.0.1;",
+		"Host:name=www.example.com;-IN_DOMAIN:->Domain:name=example.com;",
+		"Host:name=www.example.com;-RESOLVES_TO:->Interface:ip=10.0.0.1;",
+		"Interface:ip=10.0.0.1;-IN_SUBNET:->Subnet:cidr=10.0.0.0/24;",
+		"Interface:ip=10.0.0.2;-IN_SUBNET:->Subnet:cidr=10.0.0.0/24;",
+	}
+	if got := relationshipIDs(m); !reflect.DeepEqual(got, wantRelationships) {
+		t.Errorf("Got relationships %v, want %v", got, wantRelationships)
+	}
+
+	alive, _ := m.Node("Interface", map[string]interface{}{"ip": "10.0.0.1"})
+	for key, want := range map[string]interface{}{
+		"alive":            true,
+		"responding_ports": []interface{}{22, 443},
+		"probed_at":        probed.Unix(),
+		"last_alive":       probed.Unix(),
+	} {
+		if got := alive.Properties[key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Got %s %v for 10.0.0.1, want %v", key, got, want)
+		}
+	}
+	dead, _ := m.Node("Interface", map[string]interface{}{"ip": "10.0.0.2"})
+	if _, ok := dead.Properties["last_alive"]; ok || dead.Properties["alive"] != false {
+		t.Errorf("Got %v for 10.0.0.2, want it not alive", dead.Properties)
+	}
+
+	for _, r := range m.Relationships("HAS_INTERFACE") {
+		stale, checked := r.Properties["stale_ptr"]
+		switch r.From.Key["name"] {
+		case "www.example.com":
+			if checked {
+				t.Errorf("Got stale_ptr %v for an unchecked PTR", stale)
+			}
+		case "old.example.com":
+			if stale != true || r.Properties["fcrdns"] != trace2neolib.FCrDNSMismatched {
+				t.Errorf("Got %v for a mismatched PTR, want it stale", r.Properties)
+			}
+		}
+	}
+}
+
+// resolvesTo returns the properties of the RESOLVES_TO relationships in m, by
+// name and address
+func resolvesTo(m *MemorySink) map[string]map[string]interface{} {
+	got := make(map[string]map[string]interface{})
+	for _, r := range m.Relationships("RESOLVES_TO") {
+		flags := make(map[string]interface{})
+		for _, flag := range []string{"ptr", "forward"} {
+			if value, ok := r.Properties[flag]; ok {
+				flags[flag] = value
+			}
+		}
+		got[r.From.Key["name"].(string)+" "+r.To.Key["ip"].(string)] = flags
+	}
+	return got
+}
+
+func TestWriteResolvesTo(t *testing.T) {
+	m := NewMemorySink()
+	writes := []func() error{
+		// a PTR record, whose name is then confirmed by its A record
+		func() error {
+			return WriteAsset(m, &trace2neolib.Asset{Name: "www.example.com.", IPAddr: "10.0.0.1"})
+		},
+		func() error { return WriteResolution(m, "www.example.com.", "10.0.0.1") },
+		// a name with many addresses, only one of which has a PTR record
+		func() error {
+			return WriteAsset(m, &trace2neolib.Asset{
+				Name:       "lb.example.com.",
+				IPAddr:     "10.0.0.2",
+				ForwardIPs: []string{"10.0.0.2", "10.0.0.3"},
+				FCrDNS:     trace2neolib.FCrDNSConfirmed,
+			})
+		},
+		// an address with many PTR names
+		func() error {
+			return WriteAsset(m, &trace2neolib.Asset{Name: "a.example.com.", IPAddr: "10.0.0.4"})
+		},
+		func() error {
+			return WriteAsset(m, &trace2neolib.Asset{Name: "b.example.com.", IPAddr: "10.0.0.4"})
+		},
+	}
+	for _, write := range writes {
+		if err := write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]map[string]interface{}{
+		"www.example.com 10.0.0.1": {"ptr": true, "forward": true},
+		"lb.example.com 10.0.0.2":  {"ptr": true, "forward": true},
+		"lb.example.com 10.0.0.3":  {"forward": true},
+		"a.example.com 10.0.0.4":   {"ptr": true},
+		"b.example.com 10.0.0.4":   {"ptr": true},
+	}
+	if got := resolvesTo(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got RESOLVES_TO %v, want %v", got, want)
+	}
+	if n := len(m.Relationships("HAS_INTERFACE")); n != 4 {
+		t.Errorf("Got %d HAS_INTERFACE relationships, want one for each PTR record", n)
+	}
+}
@@ -0,0 +1,127 @@
+package graphSink
+
+import (
+	"context"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/dbtype"
+)
+
+// ReadQuery runs a Cypher query against database, or the default database if
+// it is empty, and writes every node, relationship and path it returns,
+// including those inside lists, to s. Nodes are keyed on the property
+// constrained for their label, see Constraints, and relationships are only
+// written if both of their nodes are returned too.
+func ReadQuery(ctx context.Context, driver neo4j.DriverWithContext, database, query string, s GraphSink) error {
+	session := driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead, DatabaseName: database})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, query, nil)
+	if err != nil {
+		return err
+	}
+	records, err := result.Collect(ctx)
+	if err != nil {
+		return err
+	}
+
+	var r queryResult
+	for _, record := range records {
+		for _, value := range record.Values {
+			r.add(value)
+		}
+	}
+
+	refs := make(map[string]NodeRef)
+	for _, n := range r.nodes {
+		node := queryNode(n)
+		refs[n.ElementId] = node.Ref()
+		if err = s.UpsertNode(node); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range r.relationships {
+		from, ok := refs[rel.StartElementId]
+		if !ok {
+			continue
+		}
+		to, ok := refs[rel.EndElementId]
+		if !ok {
+			continue
+		}
+
+		err = s.UpsertRelationship(Relationship{
+			Type:       rel.Type,
+			From:       from,
+			To:         to,
+			Key:        map[string]interface{}{"neo4j_id": rel.ElementId},
+			Properties: rel.Props,
+			Seen:       lastSeen(rel.Props),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryResult collects the graph values returned by a query
+type queryResult struct {
+	nodes         []dbtype.Node
+	relationships []dbtype.Relationship
+}
+
+func (r *queryResult) add(value interface{}) {
+	switch v := value.(type) {
+	case dbtype.Node:
+		r.nodes = append(r.nodes, v)
+	case dbtype.Relationship:
+		r.relationships = append(r.relationships, v)
+	case dbtype.Path:
+		r.nodes = append(r.nodes, v.Nodes...)
+		r.relationships = append(r.relationships, v.Relationships...)
+	case []interface{}:
+		for _, item := range v {
+			r.add(item)
+		}
+	}
+}
+
+// queryNode converts a node returned by Neo4j, keying it on the constrained
+// property of its first label or, failing that, its Neo4j element ID
+func queryNode(n dbtype.Node) Node {
+	node := Node{
+		Label:      "Node",
+		Key:        map[string]interface{}{"neo4j_id": n.ElementId},
+		Properties: make(map[string]interface{}),
+		Seen:       lastSeen(n.Props),
+	}
+	if len(n.Labels) > 0 {
+		node.Label = n.Labels[0]
+	}
+
+	for _, c := range Constraints {
+		if value, ok := n.Props[c.Property]; ok && c.Label == node.Label {
+			node.Key = map[string]interface{}{c.Property: value}
+			break
+		}
+	}
+
+	for key, value := range n.Props {
+		if _, ok := node.Key[key]; !ok {
+			node.Properties[key] = value
+		}
+	}
+	return node
+}
+
+// lastSeen returns when something read back from Neo4j was last seen, so
+// copying it keeps its seen span
+func lastSeen(properties map[string]interface{}) time.Time {
+	if seen, ok := properties["last_seen"].(int64); ok {
+		return time.Unix(seen, 0)
+	}
+	return time.Time{}
+}
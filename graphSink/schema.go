@@ -0,0 +1,174 @@
+package graphSink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kkirsche/trace2neo/cypherBuilder"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// SchemaEntry is a uniqueness constraint or index on a property of a label
+type SchemaEntry struct {
+	Label    string
+	Property string
+}
+
+// Constraints are the uniqueness constraints on the key of every label
+// trace2neo writes. MERGE relies on them both for speed and to avoid creating
+// duplicates when two writers race.
+var Constraints = []SchemaEntry{
+	{Label: "Interface", Property: "ip"},
+	{Label: "Host", Property: "name"},
+	// Router.id is reserved for routers whose interfaces have been aliased,
+	// and AS.asn for AS data, so both are keyed before anything writes them
+	{Label: "Router", Property: "id"},
+	{Label: "AS", Property: "asn"},
+	{Label: "Trace", Property: "id"},
+	{Label: "Subnet", Property: "cidr"},
+	{Label: "Domain", Property: "name"},
+}
+
+// Indexes are the indexes on properties which are commonly queried but are not
+// covered by a constraint
+var Indexes = []SchemaEntry{
+	{Label: "Trace", Property: "traced_at"},
+	{Label: "Interface", Property: "last_seen"},
+	{Label: "Host", Property: "last_seen"},
+}
+
+// CreateConstraint returns the Cypher creating the constraint
+func (c SchemaEntry) CreateConstraint() string {
+	return fmt.Sprintf("CREATE CONSTRAINT IF NOT EXISTS FOR (n:%s) REQUIRE n.%s IS UNIQUE",
+		cypherBuilder.Identifier(c.Label), cypherBuilder.Identifier(c.Property))
+}
+
+// CreateIndex returns the Cypher creating the index
+func (c SchemaEntry) CreateIndex() string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS FOR (n:%s) ON (n.%s)",
+		cypherBuilder.Identifier(c.Label), cypherBuilder.Identifier(c.Property))
+}
+
+func (c SchemaEntry) String() string {
+	return c.Label + "." + c.Property
+}
+
+// SchemaDrift is the difference between the schema trace2neo expects and the
+// one found in the database
+type SchemaDrift struct {
+	MissingConstraints    []SchemaEntry
+	UnexpectedConstraints []SchemaEntry
+	MissingIndexes        []SchemaEntry
+	UnexpectedIndexes     []SchemaEntry
+}
+
+// Empty reports whether the database schema matches the expected one
+func (d SchemaDrift) Empty() bool {
+	return len(d.MissingConstraints) == 0 && len(d.UnexpectedConstraints) == 0 &&
+		len(d.MissingIndexes) == 0 && len(d.UnexpectedIndexes) == 0
+}
+
+// InitSchema creates every constraint and index of database, or the default
+// database if it is empty, which does not exist yet
+func InitSchema(ctx context.Context, driver neo4j.DriverWithContext, database string) error {
+	drift, err := CheckSchema(ctx, driver, database)
+	if err != nil {
+		return err
+	}
+
+	session := driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite, DatabaseName: database})
+	defer session.Close(ctx)
+
+	for _, c := range drift.MissingConstraints {
+		if err = runSchema(ctx, session, c.CreateConstraint()); err != nil {
+			return fmt.Errorf("Failed to create constraint on %s: %s", c, err)
+		}
+	}
+	for _, i := range drift.MissingIndexes {
+		if err = runSchema(ctx, session, i.CreateIndex()); err != nil {
+			return fmt.Errorf("Failed to create index on %s: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// CheckSchema compares the constraints and indexes of database, or the default
+// database if it is empty, with the ones trace2neo expects. Indexes backing a
+// constraint are not reported.
+func CheckSchema(ctx context.Context, driver neo4j.DriverWithContext, database string) (SchemaDrift, error) {
+	var drift SchemaDrift
+
+	session := driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead, DatabaseName: database})
+	defer session.Close(ctx)
+
+	constraints, err := schemaEntries(ctx, session, `SHOW CONSTRAINTS YIELD type, entityType, labelsOrTypes, properties
+WHERE entityType = 'NODE' AND type CONTAINS 'UNIQUENESS'
+RETURN labelsOrTypes, properties`)
+	if err != nil {
+		return drift, err
+	}
+	indexes, err := schemaEntries(ctx, session, `SHOW INDEXES YIELD type, entityType, labelsOrTypes, properties, owningConstraint
+WHERE entityType = 'NODE' AND type <> 'LOOKUP' AND owningConstraint IS NULL
+RETURN labelsOrTypes, properties`)
+	if err != nil {
+		return drift, err
+	}
+
+	drift.MissingConstraints, drift.UnexpectedConstraints = diffSchema(Constraints, constraints)
+	drift.MissingIndexes, drift.UnexpectedIndexes = diffSchema(Indexes, indexes)
+	return drift, nil
+}
+
+func runSchema(ctx context.Context, session neo4j.SessionWithContext, query string) error {
+	result, err := session.Run(ctx, query, nil)
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume(ctx)
+	return err
+}
+
+// schemaEntries runs query, which must return labelsOrTypes and properties
+// lists, keeping the entries on a single property of a single label
+func schemaEntries(ctx context.Context, session neo4j.SessionWithContext, query string) (map[SchemaEntry]bool, error) {
+	result, err := session.Run(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	records, err := result.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[SchemaEntry]bool)
+	for _, record := range records {
+		if len(record.Values) < 2 {
+			continue
+		}
+		labels, _ := record.Values[0].([]interface{})
+		properties, _ := record.Values[1].([]interface{})
+		if len(labels) != 1 || len(properties) != 1 {
+			continue
+		}
+		label, _ := labels[0].(string)
+		property, _ := properties[0].(string)
+		entries[SchemaEntry{Label: label, Property: property}] = true
+	}
+	return entries, nil
+}
+
+func diffSchema(expected []SchemaEntry, found map[SchemaEntry]bool) (missing, unexpected []SchemaEntry) {
+	want := make(map[SchemaEntry]bool)
+	for _, e := range expected {
+		want[e] = true
+		if !found[e] {
+			missing = append(missing, e)
+		}
+	}
+	for e := range found {
+		if !want[e] {
+			unexpected = append(unexpected, e)
+		}
+	}
+	return missing, unexpected
+}
@@ -0,0 +1,95 @@
+// Package graphSink defines where the nodes and relationships discovered by
+// trace2neo are written to, whether that is Neo4j, a Cypher script or memory.
+package graphSink
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Node is a node with a single label. Key holds the properties which identify
+// the node, so upserting a node with the same label and key updates it. Seen
+// is when the node was observed and defaults to now; sinks record the span it
+// has been seen over as first_seen and last_seen.
+type Node struct {
+	Label      string
+	Key        map[string]interface{}
+	Properties map[string]interface{}
+	Seen       time.Time
+}
+
+// NodeRef refers to a node by its label and key
+type NodeRef struct {
+	Label string
+	Key   map[string]interface{}
+}
+
+// Relationship joins two nodes. Key holds the properties which identify the
+// relationship between the two nodes; with no key there is at most one
+// relationship of each type between them. Seen works as it does for Node.
+type Relationship struct {
+	Type       string
+	From       NodeRef
+	To         NodeRef
+	Key        map[string]interface{}
+	Properties map[string]interface{}
+	Seen       time.Time
+}
+
+// GraphSink receives the nodes and relationships to write. Sinks may buffer
+// writes until Flush or Close is called.
+type GraphSink interface {
+	// UpsertNode creates the node, or updates its properties if it exists
+	UpsertNode(n Node) error
+	// UpsertRelationship creates the relationship and both of its nodes, or
+	// updates its properties if it exists
+	UpsertRelationship(r Relationship) error
+	// Batch applies every write made by fn together, or none of them if fn
+	// returns an error and the sink supports it
+	Batch(fn func() error) error
+	// Flush writes any buffered writes
+	Flush() error
+	// Close flushes the sink and releases its resources
+	Close() error
+}
+
+// Ref returns a reference to the node
+func (n Node) Ref() NodeRef {
+	return NodeRef{Label: n.Label, Key: n.Key}
+}
+
+// ID returns a string uniquely identifying the node within its label
+func (n NodeRef) ID() string {
+	return n.Label + ":" + mapID(n.Key)
+}
+
+// ID returns a string uniquely identifying the relationship
+func (r Relationship) ID() string {
+	return r.From.ID() + "-" + r.Type + ":" + mapID(r.Key) + "->" + r.To.ID()
+}
+
+// seenAt returns seen as a unix time, defaulting to now
+func seenAt(seen time.Time) int64 {
+	if seen.IsZero() {
+		return time.Now().Unix()
+	}
+	return seen.Unix()
+}
+
+func mapID(m map[string]interface{}) string {
+	id := ""
+	for _, key := range sortedKeys(m) {
+		id += fmt.Sprintf("%s=%v;", key, m[key])
+	}
+	return id
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
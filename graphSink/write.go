@@ -0,0 +1,374 @@
+package graphSink
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkirsche/trace2neo/trace2neolib"
+	"github.com/kkirsche/trace2neo/warts"
+)
+
+// WriteAsset writes a resolved asset as an Interface keyed by its IP, which is
+// IN_SUBNET the Subnet the asset was found in, if any. If the address resolved
+// to a name, the Host keyed by that FQDN HAS_INTERFACE the Interface and
+// RESOLVES_TO it by PTR, and is IN_DOMAIN the hierarchy of Domains above it. If
+// the name was forward-confirmed, the Host records the forward_ips it resolves
+// to, and RESOLVES_TO their Interfaces by forward lookup, while HAS_INTERFACE
+// records the fcrdns status and whether it is a stale_ptr. If the address was
+// probed, the Interface records whether it was alive, its responding_ports and
+// when it was probed_at, and last_alive if it answered.
+func WriteAsset(s GraphSink, asset *trace2neolib.Asset) error {
+	iface := interfaceRef(asset.IPAddr)
+	return s.Batch(func() error {
+		if err := s.UpsertNode(Node{Label: iface.Label, Key: iface.Key, Properties: livenessProperties(asset)}); err != nil {
+			return err
+		}
+		if asset.Subnet != "" {
+			err := s.UpsertRelationship(Relationship{
+				Type: "IN_SUBNET",
+				From: iface,
+				To:   NodeRef{Label: "Subnet", Key: map[string]interface{}{"cidr": asset.Subnet}},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if asset.Name == "" || asset.Name == asset.IPAddr {
+			return nil
+		}
+		if asset.FCrDNS == trace2neolib.FCrDNSUnchecked {
+			return writeName(s, asset.Name, iface, time.Time{})
+		}
+
+		host := hostRef(asset.Name)
+		err := s.UpsertNode(Node{
+			Label:      host.Label,
+			Key:        host.Key,
+			Properties: map[string]interface{}{"forward_ips": asset.ForwardIPs},
+		})
+		if err != nil {
+			return err
+		}
+		err = s.UpsertRelationship(Relationship{
+			Type: "HAS_INTERFACE",
+			From: host,
+			To:   iface,
+			Properties: map[string]interface{}{
+				"fcrdns":    asset.FCrDNS,
+				"stale_ptr": asset.StalePTR(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if err = writeResolvesTo(s, host, iface, "ptr", time.Time{}); err != nil {
+			return err
+		}
+		for _, ip := range asset.ForwardIPs {
+			if err = writeResolvesTo(s, host, interfaceRef(ip), "forward", time.Time{}); err != nil {
+				return err
+			}
+		}
+		return writeDomains(s, asset.Name, time.Time{})
+	})
+}
+
+// livenessProperties returns the properties recording what probing the address
+// of asset found, nil if it wasn't probed
+func livenessProperties(asset *trace2neolib.Asset) map[string]interface{} {
+	l := asset.Liveness
+	if l == nil {
+		return nil
+	}
+
+	ports := []interface{}{}
+	for _, port := range l.Ports {
+		ports = append(ports, port)
+	}
+	properties := map[string]interface{}{
+		"alive":            l.Alive,
+		"echo_reply":       l.EchoReply,
+		"responding_ports": ports,
+		"probed_at":        l.ProbedAt.Unix(),
+	}
+	if l.Alive {
+		properties["last_alive"] = l.ProbedAt.Unix()
+	}
+	return properties
+}
+
+// WriteResolution writes an A or AAAA record as a forward RESOLVES_TO
+// relationship from the Host of name to the Interface of ip
+func WriteResolution(s GraphSink, name, ip string) error {
+	return s.Batch(func() error {
+		return writeForwardName(s, name, interfaceRef(ip), time.Time{})
+	})
+}
+
+// WriteAlias writes a CNAME record as a CNAME relationship from the Host of the
+// alias to the Host of its target
+func WriteAlias(s GraphSink, alias, target string) error {
+	return s.Batch(func() error {
+		err := s.UpsertRelationship(Relationship{
+			Type: "CNAME",
+			From: hostRef(alias),
+			To:   hostRef(target),
+		})
+		if err != nil {
+			return err
+		}
+		if err = writeDomains(s, alias, time.Time{}); err != nil {
+			return err
+		}
+		return writeDomains(s, target, time.Time{})
+	})
+}
+
+// WriteTrace writes a trace into the graph. The trace becomes a Trace node
+// linked FROM its source Interface and TO its target Interface, and every link
+// of the trace becomes a HOP relationship between Interfaces carrying the trace
+// ID, the ttl_gap of hops it spans and whether it is ambiguous. Everything is
+// recorded as seen when the trace started.
+func WriteTrace(s GraphSink, t *trace2neolib.Trace) error {
+	return s.Batch(func() error {
+		trace, err := writeTraceNode(s, t)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range t.Links() {
+			var annotations []interface{}
+			for _, annotation := range link.Annotations {
+				annotations = append(annotations, annotation)
+			}
+
+			to := interfaceRef(link.To.String())
+			if link.Name != "" {
+				if err = writeName(s, link.Name, to, t.StartTime); err != nil {
+					return err
+				}
+			}
+
+			err = s.UpsertRelationship(Relationship{
+				Type: "HOP",
+				From: interfaceRef(link.From.String()),
+				To:   to,
+				Key:  map[string]interface{}{"trace_id": trace.Key["id"], "ttl": link.TTL},
+				Properties: map[string]interface{}{
+					"rtt":         Millis(link.RTT),
+					"annotations": annotations,
+					"method":      t.Method,
+					"traced_at":   t.StartTime.Unix(),
+					"ttl_gap":     link.Gap,
+					"ambiguous":   link.Ambiguous,
+				},
+				Seen: t.StartTime,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WriteTracelb writes an MDA traceroute into the graph. It is recorded like any
+// other trace, except that its HOP relationships follow the links of the MDA
+// graph rather than TTLs.
+func WriteTracelb(s GraphSink, t *warts.Tracelb) error {
+	trace := trace2neolib.NewTrace(t.Dst, "mda", t.Start)
+	trace.ID = fmt.Sprintf("scamper-mda-%d-%d-%s-%d", t.ListID, t.CycleID, t.Dst, t.Start.UnixNano())
+	trace.Source = t.Src
+	trace.Annotate("source", "scamper")
+	trace.Annotate("list_id", strconv.FormatUint(uint64(t.ListID), 10))
+	trace.Annotate("cycle_id", strconv.FormatUint(uint64(t.CycleID), 10))
+
+	return s.Batch(func() error {
+		if _, err := writeTraceNode(s, trace); err != nil {
+			return err
+		}
+
+		// the first node of an MDA trace is the first hop, so link it to the source
+		links := t.Links
+		if len(t.Nodes) > 0 && t.Src != nil {
+			links = append([]warts.TracelbLink{{From: -1, To: 0, Hops: 1}}, links...)
+		}
+
+		for _, link := range links {
+			from := t.Src
+			if link.From >= 0 && link.From < len(t.Nodes) {
+				from = t.Nodes[link.From].Addr
+			}
+			if link.To < 0 || link.To >= len(t.Nodes) || from == nil || t.Nodes[link.To].Addr == nil {
+				continue
+			}
+
+			err := s.UpsertRelationship(Relationship{
+				Type: "HOP",
+				From: interfaceRef(from.String()),
+				To:   interfaceRef(t.Nodes[link.To].Addr.String()),
+				Key:  map[string]interface{}{"trace_id": trace.ID, "from_node": link.From, "to_node": link.To},
+				Properties: map[string]interface{}{
+					"hops":      link.Hops,
+					"rtt":       Millis(link.RTT),
+					"mda":       true,
+					"method":    trace.Method,
+					"traced_at": t.Start.Unix(),
+				},
+				Seen: t.Start,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WritePing records a ping result on the Interface which was pinged
+func WritePing(s GraphSink, p *warts.Ping) error {
+	if p.Dst == nil {
+		return nil
+	}
+
+	var rtt time.Duration
+	for _, reply := range p.Replies {
+		if reply.RTT > 0 && (rtt == 0 || reply.RTT < rtt) {
+			rtt = reply.RTT
+		}
+	}
+
+	return s.UpsertNode(Node{
+		Label: "Interface",
+		Key:   interfaceRef(p.Dst.String()).Key,
+		Properties: map[string]interface{}{
+			"ping_sent":    int(p.Sent),
+			"ping_replies": len(p.Replies),
+			"ping_rtt":     Millis(rtt),
+			"pinged_at":    p.Start.Unix(),
+		},
+		Seen: p.Start,
+	})
+}
+
+// Millis converts a round trip time to milliseconds, which is how RTTs are
+// recorded in the graph
+func Millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func writeTraceNode(s GraphSink, t *trace2neolib.Trace) (Node, error) {
+	properties := map[string]interface{}{
+		"method":    t.Method,
+		"traced_at": t.StartTime.Unix(),
+	}
+	for key, value := range t.Annotations {
+		properties[key] = value
+	}
+
+	trace := Node{
+		Label:      "Trace",
+		Key:        map[string]interface{}{"id": t.ID},
+		Properties: properties,
+		Seen:       t.StartTime,
+	}
+	if err := s.UpsertNode(trace); err != nil {
+		return trace, err
+	}
+
+	if t.Source != nil {
+		if err := s.UpsertRelationship(Relationship{Type: "FROM", From: trace.Ref(), To: interfaceRef(t.Source.String()), Seen: t.StartTime}); err != nil {
+			return trace, err
+		}
+	}
+
+	if t.Target != nil {
+		target := interfaceRef(t.Target.String())
+		if t.TargetName != "" {
+			if err := writeForwardName(s, t.TargetName, target, t.StartTime); err != nil {
+				return trace, err
+			}
+		}
+		if err := s.UpsertRelationship(Relationship{Type: "TO", From: trace.Ref(), To: target, Seen: t.StartTime}); err != nil {
+			return trace, err
+		}
+	}
+
+	return trace, nil
+}
+
+// writeName links the Host with the given name, which the PTR record of the
+// Interface holds, to the Interface and to its Domains
+func writeName(s GraphSink, name string, iface NodeRef, seen time.Time) error {
+	host := hostRef(name)
+	err := s.UpsertRelationship(Relationship{
+		Type: "HAS_INTERFACE",
+		From: host,
+		To:   iface,
+		Seen: seen,
+	})
+	if err != nil {
+		return err
+	}
+	if err = writeResolvesTo(s, host, iface, "ptr", seen); err != nil {
+		return err
+	}
+	return writeDomains(s, name, seen)
+}
+
+// writeForwardName links the Host with the given name, which resolves to the
+// Interface by forward lookup, to the Interface and to its Domains
+func writeForwardName(s GraphSink, name string, iface NodeRef, seen time.Time) error {
+	if err := writeResolvesTo(s, hostRef(name), iface, "forward", seen); err != nil {
+		return err
+	}
+	return writeDomains(s, name, seen)
+}
+
+// writeResolvesTo writes the RESOLVES_TO relationship from host to iface,
+// flagging the way it was looked up, ptr or forward. A name found both ways has
+// one relationship with both flags.
+func writeResolvesTo(s GraphSink, host, iface NodeRef, lookup string, seen time.Time) error {
+	return s.UpsertRelationship(Relationship{
+		Type:       "RESOLVES_TO",
+		From:       host,
+		To:         iface,
+		Properties: map[string]interface{}{lookup: true},
+		Seen:       seen,
+	})
+}
+
+// writeDomains links the Host with the given name to the Domain it is
+// IN_DOMAIN, and each Domain to the one above it, up to the top level domain.
+// Names which are addresses have no domains.
+func writeDomains(s GraphSink, name string, seen time.Time) error {
+	from := hostRef(name)
+	fqdn := from.Key["name"].(string)
+	if net.ParseIP(fqdn) != nil {
+		return nil
+	}
+
+	labels := strings.Split(fqdn, ".")
+	for i := 1; i < len(labels); i++ {
+		domain := NodeRef{Label: "Domain", Key: map[string]interface{}{"name": strings.Join(labels[i:], ".")}}
+		if err := s.UpsertRelationship(Relationship{Type: "IN_DOMAIN", From: from, To: domain, Seen: seen}); err != nil {
+			return err
+		}
+		from = domain
+	}
+	return nil
+}
+
+func interfaceRef(ip string) NodeRef {
+	return NodeRef{Label: "Interface", Key: map[string]interface{}{"ip": ip}}
+}
+
+// hostRef refers to a Host by its FQDN, normalised so that PTR answers (which
+// end in a dot) and names typed by hand refer to the same Host
+func hostRef(name string) NodeRef {
+	return NodeRef{Label: "Host", Key: map[string]interface{}{"name": strings.TrimSuffix(strings.ToLower(name), ".")}}
+}
@@ -0,0 +1,135 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Modes of reporting progress
+const (
+	// ModeAuto draws a bar if stderr is a terminal and logs otherwise
+	ModeAuto = "auto"
+	ModeBar  = "bar"
+	ModeLog  = "log"
+	ModeNone = "none"
+)
+
+// barWidth is the number of characters of the bar itself
+const barWidth = 30
+
+// Reporter reports the progress of a Tracker until it is stopped
+type Reporter struct {
+	tracker  *Tracker
+	mode     string
+	interval time.Duration
+	out      io.Writer
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Start reports the progress of t in mode, drawing a bar on stderr every
+// second, or logging every interval
+func Start(t *Tracker, mode string, interval time.Duration) (*Reporter, error) {
+	switch mode {
+	case ModeAuto:
+		mode = ModeLog
+		if isTerminal(os.Stderr) {
+			mode = ModeBar
+		}
+	case ModeBar, ModeLog, ModeNone:
+	default:
+		return nil, fmt.Errorf("Unsupported progress mode %s. Use auto, bar, log or none.", mode)
+	}
+
+	r := &Reporter{
+		tracker:  t,
+		mode:     mode,
+		interval: interval,
+		out:      os.Stderr,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Stop stops reporting, reporting the final progress first
+func (r *Reporter) Stop() {
+	close(r.stop)
+	<-r.stopped
+}
+
+func (r *Reporter) run() {
+	defer close(r.stopped)
+
+	sample := time.NewTicker(time.Second)
+	defer sample.Stop()
+	lastLog := time.Now()
+	for {
+		select {
+		case <-sample.C:
+			r.tracker.Sample()
+			switch {
+			case r.mode == ModeBar:
+				r.drawBar(r.tracker.Snapshot())
+			case r.mode == ModeLog && time.Since(lastLog) >= r.interval:
+				logSnapshot(r.tracker.Snapshot())
+				lastLog = time.Now()
+			}
+		case <-r.stop:
+			switch r.mode {
+			case ModeBar:
+				r.drawBar(r.tracker.Snapshot())
+				fmt.Fprintln(r.out)
+			case ModeLog:
+				logSnapshot(r.tracker.Snapshot())
+			}
+			return
+		}
+	}
+}
+
+func (r *Reporter) drawBar(s Snapshot) {
+	filled := int(s.Percent() / 100 * barWidth)
+	if filled > barWidth {
+		filled = barWidth
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+	fmt.Fprintf(r.out, "\r\033[K[%s] %5.1f%% %d/%d %.1f/s ETA %s %s (%d/%d)",
+		bar, s.Percent(), s.Done, s.Total, s.Rate, formatETA(s), s.Part, s.PartDone, s.PartTotal)
+}
+
+func logSnapshot(s Snapshot) {
+	logrus.WithFields(logrus.Fields{
+		"done":       s.Done,
+		"total":      s.Total,
+		"percent":    fmt.Sprintf("%.1f", s.Percent()),
+		"rate":       fmt.Sprintf("%.1f", s.Rate),
+		"elapsed":    s.Elapsed.Truncate(time.Second).String(),
+		"eta":        formatETA(s),
+		"part":       s.Part,
+		"part_done":  s.PartDone,
+		"part_total": s.PartTotal,
+	}).Infoln("Progress")
+}
+
+func formatETA(s Snapshot) string {
+	if s.Done >= s.Total {
+		return "0s"
+	}
+	if s.ETA == 0 {
+		return "unknown"
+	}
+	return s.ETA.Truncate(time.Second).String()
+}
+
+// isTerminal reports whether f is a character device, such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
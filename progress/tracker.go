@@ -0,0 +1,137 @@
+// Package progress tracks how far through a long running sweep trace2neo is,
+// estimating its rate and time remaining, and reports it as a progress bar on
+// a terminal or as periodic log lines otherwise.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// smoothing is the weight given to the latest rate sample in the moving
+// average, so the rate follows changes over roughly the last ten samples
+const smoothing = 0.2
+
+// Tracker tracks progress through a sweep made up of parts, such as the
+// address ranges of an assets sweep, which are worked through in order. It is
+// safe for concurrent use.
+type Tracker struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	parts []part
+	total uint64
+	done  uint64
+	start time.Time
+
+	rate       float64
+	sampledAt  time.Time
+	sampleDone uint64
+}
+
+type part struct {
+	name  string
+	total uint64
+}
+
+// NewTracker creates a tracker starting now
+func NewTracker() *Tracker {
+	return newTracker(time.Now)
+}
+
+// newTracker creates a tracker reading the time from now
+func newTracker(now func() time.Time) *Tracker {
+	start := now()
+	return &Tracker{now: now, start: start, sampledAt: start}
+}
+
+// AddPart adds a part of total items to the end of the sweep
+func (t *Tracker) AddPart(name string, total uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.parts = append(t.parts, part{name: name, total: total})
+	t.total += total
+}
+
+// Done records that n more items are done
+func (t *Tracker) Done(n uint64) {
+	t.mu.Lock()
+	t.done += n
+	t.mu.Unlock()
+}
+
+// Sample updates the moving average of the rate with the items done since the
+// last sample
+func (t *Tracker) Sample() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	elapsed := now.Sub(t.sampledAt).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	rate := float64(t.done-t.sampleDone) / elapsed
+	if t.sampleDone == 0 && t.rate == 0 {
+		t.rate = rate
+	} else {
+		t.rate = smoothing*rate + (1-smoothing)*t.rate
+	}
+	t.sampledAt, t.sampleDone = now, t.done
+}
+
+// Snapshot is the progress of a sweep at a point in time
+type Snapshot struct {
+	Done    uint64
+	Total   uint64
+	Elapsed time.Duration
+	// Rate is the moving average of items done per second
+	Rate float64
+	// ETA is the estimated time remaining, or 0 if it can't be estimated yet
+	ETA time.Duration
+	// Part is the name of the part being worked on, with PartDone of its
+	// PartTotal items done
+	Part      string
+	PartDone  uint64
+	PartTotal uint64
+}
+
+// Snapshot returns the progress so far
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := Snapshot{
+		Done:    t.done,
+		Total:   t.total,
+		Elapsed: t.now().Sub(t.start),
+		Rate:    t.rate,
+	}
+	if s.Rate == 0 && s.Elapsed > 0 {
+		s.Rate = float64(t.done) / s.Elapsed.Seconds()
+	}
+	if s.Rate > 0 && t.total > t.done {
+		s.ETA = time.Duration(float64(t.total-t.done) / s.Rate * float64(time.Second))
+	}
+
+	before := uint64(0)
+	for _, p := range t.parts {
+		if t.done < before+p.total {
+			s.Part, s.PartDone, s.PartTotal = p.name, t.done-before, p.total
+			break
+		}
+		before += p.total
+	}
+	if s.Part == "" && len(t.parts) > 0 {
+		last := t.parts[len(t.parts)-1]
+		s.Part, s.PartDone, s.PartTotal = last.name, last.total, last.total
+	}
+	return s
+}
+
+// Percent returns the percentage of items done
+func (s Snapshot) Percent() float64 {
+	if s.Total == 0 {
+		return 100
+	}
+	return 100 * float64(s.Done) / float64(s.Total)
+}
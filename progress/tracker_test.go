@@ -0,0 +1,125 @@
+package progress
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock which only moves when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newFakeTracker() (*Tracker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	return newTracker(clock.Now), clock
+}
+
+func TestTrackerRate(t *testing.T) {
+	tracker, clock := newFakeTracker()
+	tracker.AddPart("10.0.0.0-10.0.0.255", 256)
+	tracker.AddPart("10.0.2.0-10.0.2.255", 256)
+
+	// before any sample the rate is the average so far
+	clock.Advance(2 * time.Second)
+	tracker.Done(20)
+	if s := tracker.Snapshot(); s.Rate != 10 || s.Elapsed != 2*time.Second {
+		t.Errorf("Got rate %f after %s, want 10 after 2s", s.Rate, s.Elapsed)
+	}
+
+	// the first sample sets the rate, later ones are averaged in
+	tracker.Sample()
+	samples := []struct {
+		done uint64
+		rate float64
+	}{
+		{30, 0.2*30 + 0.8*10},
+		{0, 0.8 * (0.2*30 + 0.8*10)},
+	}
+	for _, sample := range samples {
+		clock.Advance(time.Second)
+		tracker.Done(sample.done)
+		tracker.Sample()
+		if s := tracker.Snapshot(); math.Abs(s.Rate-sample.rate) > 1e-9 {
+			t.Errorf("Got rate %f after %d more done, want %f", s.Rate, sample.done, sample.rate)
+		}
+	}
+
+	// sampling again at the same instant changes nothing
+	before := tracker.Snapshot().Rate
+	tracker.Sample()
+	if after := tracker.Snapshot().Rate; after != before {
+		t.Errorf("Got rate %f from an empty interval, want %f", after, before)
+	}
+}
+
+func TestTrackerETA(t *testing.T) {
+	tracker, clock := newFakeTracker()
+	tracker.AddPart("a", 100)
+
+	if s := tracker.Snapshot(); s.ETA != 0 || s.Rate != 0 {
+		t.Errorf("Got ETA %s and rate %f before anything was done, want neither", s.ETA, s.Rate)
+	}
+
+	clock.Advance(10 * time.Second)
+	tracker.Done(25)
+	tracker.Sample()
+	if s := tracker.Snapshot(); s.ETA != 30*time.Second {
+		t.Errorf("Got ETA %s with 75 to go at 2.5/s, want 30s", s.ETA)
+	}
+
+	clock.Advance(10 * time.Second)
+	tracker.Done(75)
+	tracker.Sample()
+	s := tracker.Snapshot()
+	if s.ETA != 0 || formatETA(s) != "0s" || s.Percent() != 100 {
+		t.Errorf("Got ETA %s (%s) at %.1f%% once done, want 0s at 100%%", s.ETA, formatETA(s), s.Percent())
+	}
+}
+
+func TestTrackerParts(t *testing.T) {
+	tracker, _ := newFakeTracker()
+	if s := tracker.Snapshot(); s.Part != "" || s.Percent() != 100 {
+		t.Errorf("Got part %q at %.1f%% without any parts, want none at 100%%", s.Part, s.Percent())
+	}
+
+	tracker.AddPart("10.0.0.1-10.0.0.10", 10)
+	tracker.AddPart("10.0.1.1", 1)
+	tracker.AddPart("10.0.2.1-10.0.2.5", 5)
+
+	tests := []struct {
+		done       uint64
+		part       string
+		partDone   uint64
+		partTotal  uint64
+		percentage float64
+	}{
+		{0, "10.0.0.1-10.0.0.10", 0, 10, 0},
+		{9, "10.0.0.1-10.0.0.10", 9, 10, 56.25},
+		{1, "10.0.1.1", 0, 1, 62.5},
+		{1, "10.0.2.1-10.0.2.5", 0, 5, 68.75},
+		{4, "10.0.2.1-10.0.2.5", 4, 5, 93.75},
+		// once every part is done the last one is shown complete
+		{1, "10.0.2.1-10.0.2.5", 5, 5, 100},
+	}
+	for _, test := range tests {
+		tracker.Done(test.done)
+		s := tracker.Snapshot()
+		if s.Part != test.part || s.PartDone != test.partDone || s.PartTotal != test.partTotal {
+			t.Errorf("Got %s %d/%d after %d done, want %s %d/%d",
+				s.Part, s.PartDone, s.PartTotal, s.Done, test.part, test.partDone, test.partTotal)
+		}
+		if s.Total != 16 || s.Percent() != test.percentage {
+			t.Errorf("Got %.2f%% of %d after %d done, want %.2f%% of 16", s.Percent(), s.Total, s.Done, test.percentage)
+		}
+	}
+}
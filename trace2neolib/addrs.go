@@ -0,0 +1,125 @@
+package trace2neolib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// AddrRange is a range of addresses of one family, from First to Last
+// inclusive. Its addresses are generated as they are iterated over, so ranges
+// of any size can be swept without holding them in memory.
+type AddrRange struct {
+	First net.IP
+	Last  net.IP
+}
+
+// NewAddrRange returns the range from first to last, which must be of the same
+// family with first no greater than last
+func NewAddrRange(first, last net.IP) (*AddrRange, error) {
+	first, last = normaliseIP(first), normaliseIP(last)
+	if first == nil || last == nil || len(first) != len(last) {
+		return nil, fmt.Errorf("Range %s-%s must start and end with addresses of the same family", first, last)
+	}
+	if bytes.Compare(first, last) > 0 {
+		return nil, fmt.Errorf("Range %s-%s ends before it starts", first, last)
+	}
+	return &AddrRange{First: first, Last: last}, nil
+}
+
+// PrefixRange returns the addresses of prefix which can be assigned to hosts.
+// For IPv4 the network and broadcast addresses are left out, except of /31 and
+// /32 prefixes whose every address is usable (RFC 3021). IPv6 has no broadcast
+// address, so every address of an IPv6 prefix is included. IPv4-mapped IPv6
+// prefixes, such as ::ffff:10.0.0.0/120, are IPv4 prefixes.
+func PrefixRange(prefix *net.IPNet) *AddrRange {
+	first, last := prefixBounds(prefix)
+	ones, bits := prefix.Mask.Size()
+	if bits == 8*net.IPv6len && len(first) == net.IPv4len {
+		ones, bits = ones-8*(net.IPv6len-net.IPv4len), 8*net.IPv4len
+	}
+	if bits == 8*net.IPv4len && ones < 31 {
+		incIP(first)
+		decIP(last)
+	}
+	return &AddrRange{First: first, Last: last}
+}
+
+// prefixBounds returns the first and last addresses of prefix
+func prefixBounds(prefix *net.IPNet) (first, last net.IP) {
+	first = normaliseIP(prefix.IP.Mask(prefix.Mask))
+	last = make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^prefix.Mask[len(prefix.Mask)-len(first)+i]
+	}
+	return first, last
+}
+
+// Len returns the number of addresses in the range, or math.MaxUint64 if there
+// are more than that
+func (r *AddrRange) Len() uint64 {
+	n := new(big.Int).Sub(new(big.Int).SetBytes(r.Last), new(big.Int).SetBytes(r.First))
+	n.Add(n, big.NewInt(1))
+	if !n.IsUint64() {
+		return math.MaxUint64
+	}
+	return n.Uint64()
+}
+
+// Contains reports whether ip is in the range
+func (r *AddrRange) Contains(ip net.IP) bool {
+	ip = normaliseIP(ip)
+	return len(ip) == len(r.First) && bytes.Compare(ip, r.First) >= 0 && bytes.Compare(ip, r.Last) <= 0
+}
+
+// Each calls fn with every address of the range in order, until fn returns
+// false
+func (r *AddrRange) Each(fn func(addr net.IP) bool) {
+	ip := make(net.IP, len(r.First))
+	copy(ip, r.First)
+	for {
+		if !fn(ip) || ip.Equal(r.Last) {
+			return
+		}
+		next := make(net.IP, len(ip))
+		copy(next, ip)
+		incIP(next)
+		ip = next
+	}
+}
+
+func (r *AddrRange) String() string {
+	if r.First.Equal(r.Last) {
+		return r.First.String()
+	}
+	return r.First.String() + "-" + r.Last.String()
+}
+
+// normaliseIP returns IPv4 addresses in their 4 byte form, so addresses of a
+// family always have the same length
+func normaliseIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+func incIP(ip net.IP) {
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j]++
+		if ip[j] > 0 {
+			break
+		}
+	}
+}
+
+func decIP(ip net.IP) {
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j]--
+		if ip[j] < 0xff {
+			break
+		}
+	}
+}
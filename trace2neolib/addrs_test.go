@@ -0,0 +1,98 @@
+package trace2neolib
+
+import (
+	"math"
+	"net"
+	"testing"
+)
+
+func TestPrefixRange(t *testing.T) {
+	for _, test := range []struct {
+		prefix      string
+		first, last string
+		len         uint64
+	}{
+		{"10.0.0.7/32", "10.0.0.7", "10.0.0.7", 1},
+		{"10.0.0.6/31", "10.0.0.6", "10.0.0.7", 2},
+		{"10.0.0.4/30", "10.0.0.5", "10.0.0.6", 2},
+		{"10.0.0.9/24", "10.0.0.1", "10.0.0.254", 254},
+		{"0.0.0.0/0", "0.0.0.1", "255.255.255.254", 1<<32 - 2},
+		{"2001:db8::/126", "2001:db8::", "2001:db8::3", 4},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1", 1},
+		{"2001:db8::/32", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", math.MaxUint64},
+		{"::ffff:10.0.0.0/120", "10.0.0.1", "10.0.0.254", 254},
+		{"::ffff:10.0.0.6/127", "10.0.0.6", "10.0.0.7", 2},
+		{"::ffff:10.0.0.7/128", "10.0.0.7", "10.0.0.7", 1},
+	} {
+		_, prefix, err := net.ParseCIDR(test.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := PrefixRange(prefix)
+		if r.First.String() != test.first || r.Last.String() != test.last || r.Len() != test.len {
+			t.Errorf("Got %s (%d addresses) for %s, want %s-%s (%d)", r, r.Len(), test.prefix, test.first, test.last, test.len)
+		}
+		if len(r.First) != len(r.Last) {
+			t.Errorf("Got addresses of different lengths for %s", test.prefix)
+		}
+	}
+}
+
+func TestAddrRangeEach(t *testing.T) {
+	r, err := NewAddrRange(net.ParseIP("10.0.0.254"), net.ParseIP("10.0.1.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	r.Each(func(addr net.IP) bool {
+		got = append(got, addr.String())
+		return true
+	})
+	want := []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}
+	if len(got) != len(want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Got %v, want %v", got, want)
+		}
+	}
+
+	// the last address of the family ends the range rather than wrapping
+	r, _ = NewAddrRange(net.ParseIP("255.255.255.254"), net.ParseIP("255.255.255.255"))
+	n := 0
+	r.Each(func(addr net.IP) bool {
+		n++
+		return n < 10
+	})
+	if n != 2 {
+		t.Errorf("Got %d addresses at the end of the address space, want 2", n)
+	}
+
+	// returning false stops the iteration
+	r, _ = NewAddrRange(net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::ffff"))
+	n = 0
+	r.Each(func(addr net.IP) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("Got %d addresses after stopping, want 3", n)
+	}
+}
+
+func TestNewAddrRange(t *testing.T) {
+	r, err := NewAddrRange(net.ParseIP("::ffff:10.0.0.1"), net.ParseIP("10.0.0.2"))
+	if err != nil || len(r.First) != net.IPv4len {
+		t.Errorf("Got %v, %v for a mapped and a plain IPv4 address, want an IPv4 range", r, err)
+	}
+	if !r.Contains(net.ParseIP("::ffff:10.0.0.2")) || r.Contains(net.ParseIP("10.0.0.3")) {
+		t.Errorf("Contains is wrong for %s", r)
+	}
+	if _, err := NewAddrRange(net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")); err == nil {
+		t.Errorf("Got nil error for a backwards range")
+	}
+	if _, err := NewAddrRange(net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")); err == nil {
+		t.Errorf("Got nil error for a range across families")
+	}
+}
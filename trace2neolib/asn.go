@@ -0,0 +1,86 @@
+package trace2neolib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ASNMap maps prefixes to the AS which originates them
+type ASNMap struct {
+	// byLength holds the ASN of each prefix, by prefix length and then by
+	// network address, so the most specific prefix is found without a scan
+	byLength map[int]map[string]int
+	lengths  []int
+}
+
+// ReadASNMap reads a prefix to AS mapping, one prefix per line as either
+// "10.0.0.0/8 64512" or CAIDA pfx2as' "10.0.0.0 8 64512". Where a prefix is
+// originated by several ASes, as in "64512_64513", the first is used.
+// Everything on a line after a # is a comment.
+func ReadASNMap(r io.Reader) (*ASNMap, error) {
+	m := &ASNMap{byLength: make(map[int]map[string]int)}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 2:
+		case 3:
+			fields = []string{fields[0] + "/" + fields[1], fields[2]}
+		default:
+			return nil, fmt.Errorf("Failed to parse line %d: expected a prefix and an ASN", n)
+		}
+
+		_, prefix, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse line %d: %s", n, err)
+		}
+		origin := strings.FieldsFunc(fields[1], func(r rune) bool { return r == '_' || r == ',' })
+		if len(origin) == 0 {
+			return nil, fmt.Errorf("Failed to parse line %d: no ASN", n)
+		}
+		asn, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(origin[0]), "AS"))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ASN %s on line %d: %s", fields[1], n, err)
+		}
+		m.add(prefix, asn)
+	}
+	return m, scanner.Err()
+}
+
+func (m *ASNMap) add(prefix *net.IPNet, asn int) {
+	ones, bits := prefix.Mask.Size()
+	length := ones + 8*net.IPv6len - bits
+	if m.byLength[length] == nil {
+		m.byLength[length] = make(map[string]int)
+		m.lengths = append(m.lengths, length)
+	}
+	m.byLength[length][prefix.IP.To16().String()] = asn
+}
+
+// Lookup returns the AS originating the most specific prefix containing ip
+func (m *ASNMap) Lookup(ip net.IP) (int, bool) {
+	ip = ip.To16()
+	if ip == nil {
+		return 0, false
+	}
+	best, asn := -1, 0
+	for _, length := range m.lengths {
+		if length <= best {
+			continue
+		}
+		if a, ok := m.byLength[length][ip.Mask(net.CIDRMask(length, 8*net.IPv6len)).String()]; ok {
+			best, asn = length, a
+		}
+	}
+	return asn, best >= 0
+}
@@ -0,0 +1,46 @@
+package trace2neolib
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestASNMap(t *testing.T) {
+	m, err := ReadASNMap(strings.NewReader(`# prefix asn
+10.0.0.0/8 64512
+10.1.0.0/16 AS64513
+192.0.2.0	24	64514_64515
+2001:db8::/32 64516 # documentation
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for addr, want := range map[string]int{
+		"10.0.0.1":    64512,
+		"10.1.2.3":    64513,
+		"192.0.2.200": 64514,
+		"2001:db8::1": 64516,
+	} {
+		if asn, ok := m.Lookup(net.ParseIP(addr)); !ok || asn != want {
+			t.Errorf("Got %d, %t for %s, want %d", asn, ok, addr, want)
+		}
+	}
+	for _, addr := range []string{"11.0.0.1", "2001:db9::1", "::ffff:0:0"} {
+		if asn, ok := m.Lookup(net.ParseIP(addr)); ok {
+			t.Errorf("Got %d for %s, want no AS", asn, addr)
+		}
+	}
+	if _, ok := m.Lookup(nil); ok {
+		t.Errorf("Got an AS for a nil address")
+	}
+}
+
+func TestReadASNMapErrors(t *testing.T) {
+	for _, line := range []string{"10.0.0.0/8", "10.0.0.0/33 64512", "10.0.0.0/8 private", "10.0.0.0 8 64512 extra"} {
+		if _, err := ReadASNMap(strings.NewReader(line)); err == nil {
+			t.Errorf("Got nil error for %q, want one", line)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package trace2neolib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AtlasTraceroute is a single RIPE Atlas traceroute result, as returned by the
+// measurement results API or a downloaded result file
+type AtlasTraceroute struct {
+	FirmwareVersion int        `json:"fw"`
+	MeasurementID   int        `json:"msm_id"`
+	ProbeID         int        `json:"prb_id"`
+	Type            string     `json:"type"`
+	AddressFamily   int        `json:"af"`
+	Protocol        string     `json:"proto"`
+	ParisID         int        `json:"paris_id"`
+	Size            int        `json:"size"`
+	From            string     `json:"from"`
+	SrcAddr         string     `json:"src_addr"`
+	DstAddr         string     `json:"dst_addr"`
+	DstName         string     `json:"dst_name"`
+	Timestamp       int64      `json:"timestamp"`
+	EndTime         int64      `json:"endtime"`
+	Result          []AtlasHop `json:"result"`
+}
+
+// AtlasHop holds every reply received for a single TTL
+type AtlasHop struct {
+	Hop    int          `json:"hop"`
+	Error  string       `json:"error"`
+	Result []AtlasReply `json:"result"`
+}
+
+// AtlasReply is a single probe reply. A reply with X set to "*" is a timeout,
+// Late counts packets which arrived after the probe had timed out and Err
+// carries the ICMP unreachable marker (a letter such as "N" or "H", or the raw
+// ICMP code)
+type AtlasReply struct {
+	From    string          `json:"from"`
+	RTT     float64         `json:"rtt"`
+	Size    int             `json:"size"`
+	TTL     int             `json:"ttl"`
+	X       string          `json:"x"`
+	Late    int             `json:"late"`
+	Err     json.RawMessage `json:"err"`
+	ICMPExt *AtlasICMPExt   `json:"icmpext"`
+}
+
+// AtlasICMPExt holds the RFC 4884 ICMP extension objects of a reply
+type AtlasICMPExt struct {
+	Version int                  `json:"version"`
+	RFC4884 int                  `json:"rfc4884"`
+	Objects []AtlasICMPExtObject `json:"obj"`
+}
+
+// AtlasICMPExtObject is a single ICMP extension object. Only MPLS label stacks
+// (class 1, type 1) are decoded by Atlas
+type AtlasICMPExtObject struct {
+	Class int         `json:"class"`
+	Type  int         `json:"type"`
+	MPLS  []AtlasMPLS `json:"mpls"`
+}
+
+// AtlasMPLS is a single MPLS label stack entry
+type AtlasMPLS struct {
+	Exp   int `json:"exp"`
+	Label int `json:"label"`
+	S     int `json:"s"`
+	TTL   int `json:"ttl"`
+}
+
+// ParseAtlasResults reads RIPE Atlas traceroute results. Both the JSON array
+// returned by the results API and the one-result-per-line format are supported.
+// Results of any type other than traceroute are skipped.
+func ParseAtlasResults(r io.Reader) ([]AtlasTraceroute, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var results []AtlasTraceroute
+	dec := json.NewDecoder(br)
+	if first == '[' {
+		if err = dec.Decode(&results); err != nil {
+			return nil, fmt.Errorf("Failed to decode Atlas results: %s", err)
+		}
+	} else {
+		for {
+			var result AtlasTraceroute
+			err = dec.Decode(&result)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Failed to decode Atlas result #%d: %s", len(results)+1, err)
+			}
+			results = append(results, result)
+		}
+	}
+
+	var traceroutes []AtlasTraceroute
+	for _, result := range results {
+		if result.Type != "" && result.Type != "traceroute" {
+			continue
+		}
+		traceroutes = append(traceroutes, result)
+	}
+
+	return traceroutes, nil
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
+
+// Source returns the address the traceroute was sent from, preferring the
+// public address of the probe over its (often private) source address
+func (a *AtlasTraceroute) Source() string {
+	if a.From != "" {
+		return a.From
+	}
+	return a.SrcAddr
+}
+
+// Trace converts the Atlas result into a Trace, recording the measurement and
+// probe it came from as annotations and in the trace ID. Hops which Atlas
+// failed to send are dropped and late replies are kept without an RTT.
+func (a *AtlasTraceroute) Trace() *Trace {
+	t := NewTrace(net.ParseIP(a.DstAddr), strings.ToLower(a.Protocol), time.Unix(a.Timestamp, 0).UTC())
+	t.ID = fmt.Sprintf("atlas-%d-%d-%d", a.MeasurementID, a.ProbeID, a.Timestamp)
+	t.Source = net.ParseIP(a.Source())
+	t.TargetName = a.DstName
+	t.Annotate("source", "ripe-atlas")
+	t.Annotate("msm_id", strconv.Itoa(a.MeasurementID))
+	t.Annotate("prb_id", strconv.Itoa(a.ProbeID))
+
+	for _, atlasHop := range a.Result {
+		if atlasHop.Error != "" {
+			continue
+		}
+
+		hop := Hop{TTL: atlasHop.Hop}
+		for _, atlasReply := range atlasHop.Result {
+			if atlasReply.X == "*" || atlasReply.From == "" {
+				hop.Replies = append(hop.Replies, Reply{Timeout: true})
+				continue
+			}
+
+			reply := Reply{IP: net.ParseIP(atlasReply.From)}
+			if atlasReply.Late > 0 {
+				reply.Annotations = append(reply.Annotations, "late")
+			} else {
+				reply.RTT = time.Duration(atlasReply.RTT * float64(time.Millisecond))
+			}
+			if marker := atlasReply.ErrString(); marker != "" {
+				reply.Annotations = append(reply.Annotations, "!"+marker)
+			}
+			for _, label := range atlasReply.MPLSLabels() {
+				reply.Annotations = append(reply.Annotations, fmt.Sprintf("mpls:%d", label))
+			}
+			hop.Replies = append(hop.Replies, reply)
+		}
+		t.Hops = append(t.Hops, hop)
+	}
+
+	return t
+}
+
+// ErrString returns the ICMP error marker of the reply, if any
+func (r *AtlasReply) ErrString() string {
+	if len(r.Err) == 0 {
+		return ""
+	}
+
+	var marker string
+	if err := json.Unmarshal(r.Err, &marker); err == nil {
+		return marker
+	}
+
+	var code int
+	if err := json.Unmarshal(r.Err, &code); err == nil {
+		return strconv.Itoa(code)
+	}
+
+	return strings.Trim(string(r.Err), `"`)
+}
+
+// MPLSLabels returns the MPLS labels reported in the ICMP extensions of the
+// reply, outermost first
+func (r *AtlasReply) MPLSLabels() []int {
+	if r.ICMPExt == nil {
+		return nil
+	}
+
+	var labels []int
+	for _, obj := range r.ICMPExt.Objects {
+		for _, entry := range obj.MPLS {
+			labels = append(labels, entry.Label)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,108 @@
+package trace2neolib
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAtlasFixture(t *testing.T, name string) []AtlasTraceroute {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	results, err := ParseAtlasResults(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestParseAtlasResults(t *testing.T) {
+	array := readAtlasFixture(t, "atlas.json")
+	if len(array) != 1 {
+		t.Fatalf("Got %d results from the array, want the one traceroute and the ping skipped", len(array))
+	}
+	lines := readAtlasFixture(t, "atlas.jsonl")
+	if len(lines) != 2 {
+		t.Fatalf("Got %d results from the lines, want 2", len(lines))
+	}
+	if !reflect.DeepEqual(array[0], lines[0]) {
+		t.Errorf("Got %+v from the lines, want %+v as from the array", lines[0], array[0])
+	}
+
+	result := lines[1]
+	if result.MeasurementID != 6001 || result.ProbeID != 7040 || result.AddressFamily != 6 || result.Protocol != "UDP" {
+		t.Errorf("Got measurement %d, probe %d, af %d, proto %s, want 6001, 7040, 6 and UDP",
+			result.MeasurementID, result.ProbeID, result.AddressFamily, result.Protocol)
+	}
+	if result.Source() != "2001:db8::100" {
+		t.Errorf("Got source %s for a result without from, want the src_addr", result.Source())
+	}
+}
+
+func TestParseAtlasResultsErrors(t *testing.T) {
+	for _, input := range []string{"", "  \n"} {
+		if results, err := ParseAtlasResults(strings.NewReader(input)); err != nil || len(results) != 0 {
+			t.Errorf("Got %v, %v for %q, want no results", results, err, input)
+		}
+	}
+	for _, input := range []string{`[{"type":"traceroute"}`, "{\"type\":\"traceroute\"}\n{\"type\":"} {
+		if _, err := ParseAtlasResults(strings.NewReader(input)); err == nil {
+			t.Errorf("Got nil error for %q, want one", input)
+		}
+	}
+}
+
+func TestAtlasTrace(t *testing.T) {
+	trace := readAtlasFixture(t, "atlas.json")[0].Trace()
+
+	if trace.ID != "atlas-5001-6012-1700000000" {
+		t.Errorf("Got ID %s, want atlas-5001-6012-1700000000", trace.ID)
+	}
+	if !trace.Source.Equal(net.ParseIP("198.51.100.20")) || !trace.Target.Equal(net.ParseIP("193.0.14.129")) {
+		t.Errorf("Got %s to %s, want 198.51.100.20 to 193.0.14.129", trace.Source, trace.Target)
+	}
+	if trace.TargetName != "k.root-servers.net" || trace.Method != "icmp" {
+		t.Errorf("Got target name %s and method %s, want k.root-servers.net and icmp", trace.TargetName, trace.Method)
+	}
+	if !trace.StartTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Got start time %s, want the timestamp", trace.StartTime)
+	}
+	wantAnnotations := map[string]string{"source": "ripe-atlas", "msm_id": "5001", "prb_id": "6012"}
+	if !reflect.DeepEqual(trace.Annotations, wantAnnotations) {
+		t.Errorf("Got annotations %v, want %v", trace.Annotations, wantAnnotations)
+	}
+
+	want := []Hop{
+		{TTL: 1, Replies: []Reply{
+			{IP: net.ParseIP("192.168.1.1"), RTT: ms(1.204)},
+			{IP: net.ParseIP("192.168.1.1"), RTT: ms(0.987)},
+			{IP: net.ParseIP("192.168.1.1"), RTT: ms(1.05)},
+		}},
+		{TTL: 2, Replies: []Reply{{Timeout: true}, {Timeout: true}, {Timeout: true}}},
+		{TTL: 3, Replies: []Reply{
+			{IP: net.ParseIP("203.0.113.1"), RTT: ms(9.811), Annotations: []string{"mpls:24005", "mpls:16"}},
+			{IP: net.ParseIP("203.0.113.1"), Annotations: []string{"late"}},
+			{Timeout: true},
+		}},
+		{TTL: 5, Replies: []Reply{
+			{IP: net.ParseIP("193.0.14.129"), RTT: ms(20.402)},
+			{IP: net.ParseIP("193.0.14.129"), RTT: ms(20.511), Annotations: []string{"!N"}},
+			{IP: net.ParseIP("193.0.14.129"), RTT: ms(20.39), Annotations: []string{"!13"}},
+		}},
+	}
+	if len(trace.Hops) != len(want) {
+		t.Fatalf("Got %d hops, want %d with the hop Atlas failed to send dropped", len(trace.Hops), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(trace.Hops[i], want[i]) {
+			t.Errorf("Got hop %+v, want %+v", trace.Hops[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package trace2neolib
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// ForwardResolver looks up the addresses a name resolves to. Failed lookups
+// return a *ResolveError saying why they failed.
+type ForwardResolver interface {
+	LookupHost(name string) ([]string, error)
+}
+
+// Forward-confirmed reverse DNS statuses of an Asset
+const (
+	// FCrDNSUnchecked is an asset whose name was not looked up, or whose
+	// lookup failed
+	FCrDNSUnchecked = ""
+	// FCrDNSConfirmed is an asset whose name resolves back to its address
+	FCrDNSConfirmed = "confirmed"
+	// FCrDNSMismatched is an asset whose name does not resolve back to its
+	// address, so its PTR record is likely stale
+	FCrDNSMismatched = "mismatched"
+)
+
+// LookupForward looks up the addresses name resolves to, recording them in
+// r.Forward. A name which does not exist is recorded as resolving to nothing,
+// while other failures, such as timeouts, are not recorded at all.
+func (r *ResolvedAddr) LookupForward(resolver ForwardResolver, name string) {
+	addrs, err := resolver.LookupHost(name)
+	if err != nil {
+		switch FailureReason(err) {
+		case ReasonNXDomain, ReasonNoAnswer:
+			addrs = []string{}
+		default:
+			return
+		}
+	}
+
+	if r.Forward == nil {
+		r.Forward = make(map[string][]string)
+	}
+	r.Forward[name] = addrs
+}
+
+// fcrdnsStatus returns whether addr is among the forward addresses of a name
+func fcrdnsStatus(addr string, forward []string) string {
+	ip := net.ParseIP(addr)
+	for _, f := range forward {
+		if ip.Equal(net.ParseIP(f)) {
+			return FCrDNSConfirmed
+		}
+	}
+	return FCrDNSMismatched
+}
+
+func (SystemResolver) LookupHost(name string) ([]string, error) {
+	addrs, err := net.LookupHost(name)
+	if err != nil {
+		return nil, &ResolveError{Addr: name, Reason: systemReason(err), Err: err}
+	}
+	return addrs, nil
+}
+
+// LookupHost sends A and AAAA queries for name
+func (r *DNSResolver) LookupHost(name string) ([]string, error) {
+	var (
+		addrs  []string
+		reason = ReasonNoAnswer
+	)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		answer, err := r.exchange(dns.Fqdn(name), qtype)
+		if err != nil {
+			return nil, &ResolveError{Addr: name, Server: r.Server, Reason: exchangeReason(err), Err: err}
+		}
+		if answer.Rcode == dns.RcodeNameError {
+			reason = ReasonNXDomain
+			continue
+		}
+		if reason := rcodeReason(answer.Rcode); reason != "" {
+			return nil, &ResolveError{Addr: name, Server: r.Server, Reason: reason, Err: fmt.Errorf("%s", dns.RcodeToString[answer.Rcode])}
+		}
+
+		for _, rr := range answer.Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, rr.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, rr.AAAA.String())
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, &ResolveError{Addr: name, Server: r.Server, Reason: reason, Err: fmt.Errorf("No A or AAAA records for %s", name)}
+	}
+	return addrs, nil
+}
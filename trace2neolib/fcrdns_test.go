@@ -0,0 +1,122 @@
+package trace2neolib
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeForwardResolver answers each name with its addrs, or fails with its
+// reason
+type fakeForwardResolver map[string]struct {
+	addrs  []string
+	reason string
+}
+
+func (f fakeForwardResolver) LookupHost(name string) ([]string, error) {
+	answer, ok := f[name]
+	if !ok {
+		return nil, errors.New("unexpected lookup of " + name)
+	}
+	if answer.reason != "" {
+		return nil, &ResolveError{Addr: name, Reason: answer.reason, Err: errors.New(answer.reason)}
+	}
+	return answer.addrs, nil
+}
+
+func TestFCrDNS(t *testing.T) {
+	resolver := fakeForwardResolver{
+		"v4.example.com.":     {addrs: []string{"192.0.2.10"}},
+		"other.example.com.":  {addrs: []string{"192.0.2.11", "192.0.2.12"}},
+		"gone.example.com.":   {reason: ReasonNXDomain},
+		"empty.example.com.":  {reason: ReasonNoAnswer},
+		"slow.example.com.":   {reason: ReasonTimeout},
+		"broken.example.com.": {reason: ReasonServFail},
+		"dual.example.com.":   {addrs: []string{"192.0.2.20", "2001:DB8:0:0::20"}},
+		"v6only.example.com.": {addrs: []string{"2001:db8::10"}},
+	}
+
+	tests := []struct {
+		addr, name string
+		forward    []string
+		recorded   bool
+		status     string
+	}{
+		{"192.0.2.10", "v4.example.com.", []string{"192.0.2.10"}, true, FCrDNSConfirmed},
+		{"192.0.2.10", "other.example.com.", []string{"192.0.2.11", "192.0.2.12"}, true, FCrDNSMismatched},
+		{"192.0.2.10", "gone.example.com.", []string{}, true, FCrDNSMismatched},
+		{"192.0.2.10", "empty.example.com.", []string{}, true, FCrDNSMismatched},
+		{"192.0.2.10", "slow.example.com.", nil, false, FCrDNSUnchecked},
+		{"192.0.2.10", "broken.example.com.", nil, false, FCrDNSUnchecked},
+		// addresses are compared as addresses, not as text
+		{"2001:db8::20", "dual.example.com.", []string{"192.0.2.20", "2001:DB8:0:0::20"}, true, FCrDNSConfirmed},
+		{"192.0.2.20", "dual.example.com.", []string{"192.0.2.20", "2001:DB8:0:0::20"}, true, FCrDNSConfirmed},
+		{"192.0.2.10", "v6only.example.com.", []string{"2001:db8::10"}, true, FCrDNSMismatched},
+	}
+	for _, test := range tests {
+		resolved := &ResolvedAddr{Addr: test.addr, Names: []string{test.name}}
+		resolved.LookupForward(resolver, test.name)
+
+		forward, recorded := resolved.Forward[test.name]
+		if recorded != test.recorded || !reflect.DeepEqual(forward, test.forward) {
+			t.Errorf("Got forward %v (recorded %t) for %s, want %v (recorded %t)",
+				forward, recorded, test.name, test.forward, test.recorded)
+		}
+
+		assets := ResolvedAddrToAsset(resolved, test.addr)
+		if len(assets) != 1 {
+			t.Fatalf("Got %d assets for %s, want 1", len(assets), test.name)
+		}
+		if assets[0].FCrDNS != test.status {
+			t.Errorf("Got status %q for %s from %s, want %q", assets[0].FCrDNS, test.addr, test.name, test.status)
+		}
+		if !reflect.DeepEqual(assets[0].ForwardIPs, test.forward) {
+			t.Errorf("Got forward IPs %v for %s, want %v", assets[0].ForwardIPs, test.name, test.forward)
+		}
+	}
+}
+
+func TestDNSResolverLookupHost(t *testing.T) {
+	r := testResolver(t, &queryLog{})
+
+	tests := []struct {
+		name   string
+		addrs  []string
+		reason string
+	}{
+		{"dual.example.com", []string{"10.0.0.1", "2001:db8::1"}, ""},
+		{"v6.example.com.", []string{"2001:db8::2"}, ""},
+		{"gone.example.com.", nil, ReasonNXDomain},
+		{"host.example.com.", nil, ReasonNoAnswer},
+	}
+	for _, test := range tests {
+		addrs, err := r.LookupHost(test.name)
+		if test.reason != "" {
+			if FailureReason(err) != test.reason {
+				t.Errorf("Got %v for %s, want reason %s", err, test.name, test.reason)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(addrs, test.addrs) {
+			t.Errorf("Got %v, %v for %s, want %v", addrs, err, test.name, test.addrs)
+		}
+	}
+
+	resolved, err := r.LookupAddr("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved.LookupForward(r, "dual.example.com.")
+	resolved.LookupForward(r, "gone.example.com.")
+	resolved.Names = append(resolved.Names, "dual.example.com.", "gone.example.com.")
+	want := map[string]string{
+		"host.example.com.": FCrDNSUnchecked,
+		"dual.example.com.": FCrDNSConfirmed,
+		"gone.example.com.": FCrDNSMismatched,
+	}
+	for _, asset := range ResolvedAddrToAsset(resolved, "10.0.0.1") {
+		if asset.FCrDNS != want[asset.Name] {
+			t.Errorf("Got status %q for %s, want %q", asset.FCrDNS, asset.Name, want[asset.Name])
+		}
+	}
+}
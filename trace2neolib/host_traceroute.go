@@ -5,22 +5,45 @@ import (
 	"fmt"
 	"net"
 	"os/exec"
-	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 )
 
-type TracerouteResults []TracerouteResult
+// TraceHost runs the system traceroute towards destination and parses its
+// output into a Trace
+func TraceHost(destination net.IP) (*Trace, error) {
+	t := NewTrace(destination, "udp", time.Now().UTC())
+	t.Source = SourceAddrFor(destination)
 
-type TracerouteResult struct {
-	Hop         string
-	Destination string
-	DNSName     string
-	IP          string
-	RTT1        string
-	RTT2        string
-	RTT3        string
+	result, err := RunTraceroute(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Hops, err = ProcessTracerouteOutput(result)
+	if err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// SourceAddrFor returns the local address the kernel would route packets to
+// destination from. No packets are sent.
+func SourceAddrFor(destination net.IP) net.IP {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: destination, Port: 33434})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP
+	}
+	return nil
 }
 
 func RunTraceroute(destination net.IP) (string, error) {
@@ -39,64 +62,68 @@ func RunTraceroute(destination net.IP) (string, error) {
 	return out.String(), nil
 }
 
-func ProcessTracerouteOutput(result string) (TracerouteResults, error) {
-	var processedResults TracerouteResults
-	resultLines := strings.Split(result, "\n")
-	for _, line := range resultLines {
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine == "" {
+// ProcessTracerouteOutput parses the output of the system traceroute into hops.
+// Each line holds the hop number followed by, for every probe, either a * for
+// a timeout or an RTT which belongs to the most recently named address and may
+// be followed by a !-marker such as !H. Probes are numbered by their position
+// on the line.
+func ProcessTracerouteOutput(result string) ([]Hop, error) {
+	var hops []Hop
+	for _, line := range strings.Split(result, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
 			continue
 		}
-		splitLine := strings.Split(trimmedLine, "  ")
-		var processedResult TracerouteResult
-		for i, item := range splitLine {
-			switch i {
-			case 0:
-				processedResult.Hop = strings.TrimSpace(item)
-			case 1:
-				processedResult.Destination = strings.TrimSpace(item)
-			case 2:
-				processedResult.RTT1 = strings.TrimSpace(item)
-			case 3:
-				processedResult.RTT2 = strings.TrimSpace(item)
-			case 4:
-				processedResult.RTT3 = strings.TrimSpace(item)
-			}
-		}
-		processedResults = append(processedResults, processedResult)
-	}
-
-	processedResults, err := processDestinationToDNSAndIP(processedResults)
-	if err != nil {
-		return processedResults, err
-	}
 
-	return processedResults, nil
-}
-
-func processDestinationToDNSAndIP(processedResults TracerouteResults) (TracerouteResults, error) {
-	hostAndIPRegexp, err := regexp.Compile(`(?P<dns>[\w-\.]+) \((?P<ip>\d+\.\d+\.\d+\.\d+)\)`)
-	if err != nil {
-		return processedResults, err
-	}
+		ttl, err := strconv.Atoi(fields[0])
+		if err != nil {
+			// the "traceroute to" banner and any warnings
+			continue
+		}
 
-	var furtherProcessedResults TracerouteResults
-	for _, result := range processedResults {
-		match := hostAndIPRegexp.FindStringSubmatch(result.Destination)
-		if match != nil {
-			for i, name := range hostAndIPRegexp.SubexpNames() {
-				if i > 0 && i <= len(match) {
-					if name == "dns" {
-						result.DNSName = match[i]
-					} else if name == "ip" {
-						result.IP = match[i]
-					}
+		hop := Hop{TTL: ttl}
+		var (
+			name  string
+			ip    net.IP
+			probe int
+		)
+		for i := 1; i < len(fields); i++ {
+			field := fields[i]
+			switch {
+			case field == "*":
+				probe++
+				hop.Replies = append(hop.Replies, Reply{Timeout: true, Probe: probe})
+			case strings.HasPrefix(field, "!"):
+				if n := len(hop.Replies); n > 0 {
+					hop.Replies[n-1].Annotations = append(hop.Replies[n-1].Annotations, field)
+				}
+			case i+1 < len(fields) && fields[i+1] == "ms":
+				rtt, err := strconv.ParseFloat(field, 64)
+				if err != nil {
+					return hops, fmt.Errorf("Invalid RTT %s on hop %d", field, ttl)
+				}
+				probe++
+				hop.Replies = append(hop.Replies, Reply{
+					IP:    ip,
+					Name:  name,
+					RTT:   time.Duration(rtt * float64(time.Millisecond)),
+					Probe: probe,
+				})
+				i++
+			case strings.HasPrefix(field, "(") && strings.HasSuffix(field, ")"):
+				ip = net.ParseIP(strings.Trim(field, "()"))
+			default:
+				// either a name, followed by its address in parentheses, or a
+				// bare address when names are not being resolved
+				name = field
+				ip = net.ParseIP(field)
+				if ip != nil {
+					name = ""
 				}
 			}
 		}
-
-		furtherProcessedResults = append(furtherProcessedResults, result)
+		hops = append(hops, hop)
 	}
 
-	return furtherProcessedResults, nil
+	return hops, nil
 }
@@ -0,0 +1,73 @@
+package trace2neolib
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ms(f float64) time.Duration {
+	return time.Duration(f * float64(time.Millisecond))
+}
+
+func TestProcessTracerouteOutput(t *testing.T) {
+	output, err := ioutil.ReadFile(filepath.Join("testdata", "traceroute.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hops, err := ProcessTracerouteOutput(string(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	named := func(name, ip string, probe int, rtt float64, annotations ...string) Reply {
+		r := reply(ip, probe, ms(rtt), annotations...)
+		r.Name = name
+		return r
+	}
+	want := []Hop{
+		{TTL: 1, Replies: []Reply{
+			named("gw.home.lan", "192.168.1.1", 1, 0.512),
+			named("gw.home.lan", "192.168.1.1", 2, 0.478),
+			named("gw.home.lan", "192.168.1.1", 3, 0.455),
+		}},
+		{TTL: 2, Replies: []Reply{{Timeout: true, Probe: 1}, {Timeout: true, Probe: 2}, {Timeout: true, Probe: 3}}},
+		{TTL: 3, Replies: []Reply{
+			reply("10.20.0.1", 1, ms(8.112)),
+			reply("10.20.0.5", 2, ms(8.532)),
+			reply("10.20.0.1", 3, ms(8.401)),
+		}},
+		{TTL: 4, Replies: []Reply{
+			{Timeout: true, Probe: 1},
+			named("core1.isp.net", "203.0.113.9", 2, 12.004),
+			{Timeout: true, Probe: 3},
+		}},
+		{TTL: 5, Replies: []Reply{
+			named("edge.example.net", "198.51.100.7", 1, 20.117, "!H"),
+			named("edge.example.net", "198.51.100.7", 2, 20.301, "!H"),
+			{Timeout: true, Probe: 3},
+		}},
+		{TTL: 6, Replies: []Reply{
+			reply("93.184.216.34", 1, ms(21.551), "!N"),
+			reply("93.184.216.34", 2, ms(21.489)),
+			reply("93.184.216.34", 3, ms(21.602)),
+		}},
+	}
+
+	if len(hops) != len(want) {
+		t.Fatalf("Got %d hops, want %d", len(hops), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(hops[i], want[i]) {
+			t.Errorf("Got hop %+v, want %+v", hops[i], want[i])
+		}
+	}
+}
+
+func TestProcessTracerouteOutputBadRTT(t *testing.T) {
+	if _, err := ProcessTracerouteOutput(" 1  10.0.0.1 (10.0.0.1)  fast ms\n"); err == nil {
+		t.Errorf("Got nil error for an RTT which isn't a number, want one")
+	}
+}
@@ -3,25 +3,101 @@ package trace2neolib
 import (
 	"fmt"
 	"net"
-	"strings"
+	"time"
+
+	"github.com/kkirsche/trace2neo/traceroute"
+	"github.com/miekg/dns"
 )
 
 type ResolvedAddr struct {
 	Addr  string
 	Names []string
+	// Forward holds the addresses each of Names resolves to, for the names
+	// which were looked up
+	Forward map[string][]string
 }
 
+// Asset is an address, along with one of the names it resolved to if any. If
+// the name was looked up, ForwardIPs are the addresses it resolves to and
+// FCrDNS says whether they include IPAddr. Subnet is the CIDR block the
+// address was found in, if known, and Liveness what probing the address found,
+// if it was probed.
 type Asset struct {
-	ShortName string
-	Label     string
-	Name      string
-	IPAddr    string
+	Name       string
+	IPAddr     string
+	ForwardIPs []string
+	FCrDNS     string
+	Subnet     string
+	Liveness   *traceroute.Liveness
+}
+
+// StalePTR reports whether the name of the asset no longer resolves to its
+// address
+func (a *Asset) StalePTR() bool {
+	return a.FCrDNS == FCrDNSMismatched
+}
+
+// Resolver looks up the names an address resolves to. Failed lookups return a
+// *ResolveError saying why they failed.
+type Resolver interface {
+	LookupAddr(addr string) (*ResolvedAddr, error)
+}
+
+// Reasons a lookup can fail for
+const (
+	ReasonNXDomain = "nxdomain"
+	ReasonServFail = "servfail"
+	ReasonRefused  = "refused"
+	ReasonTimeout  = "timeout"
+	ReasonNoAnswer = "no-answer"
+	ReasonError    = "error"
+)
+
+// ResolveError is a failed lookup of Addr, classified by Reason
+type ResolveError struct {
+	Addr   string
+	Server string
+	Reason string
+	Err    error
 }
 
+func (e *ResolveError) Error() string {
+	if e.Server != "" {
+		return fmt.Sprintf("Lookup of %s on %s failed (%s): %s", e.Addr, e.Server, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("Lookup of %s failed (%s): %s", e.Addr, e.Reason, e.Err)
+}
+
+// FailureReason returns why a lookup failed, ReasonError if err does not say
+func FailureReason(err error) string {
+	if e, ok := err.(*ResolveError); ok {
+		return e.Reason
+	}
+	return ReasonError
+}
+
+// Answered reports whether a lookup got an answer, even if the answer is that
+// the address has no name, rather than failing with an error such as a timeout
+func Answered(err error) bool {
+	if err == nil {
+		return true
+	}
+	reason := FailureReason(err)
+	return reason == ReasonNXDomain || reason == ReasonNoAnswer
+}
+
+// SystemResolver looks addresses up with the operating system's resolver
+type SystemResolver struct{}
+
+func (SystemResolver) LookupAddr(addr string) (*ResolvedAddr, error) {
+	return ResolveAddr(addr)
+}
+
+// ResolveAddr looks addr up with the operating system's resolver
 func ResolveAddr(addr string) (*ResolvedAddr, error) {
 	names, err := net.LookupAddr(addr)
 	if err != nil {
-		return nil, err
+		return nil, &ResolveError{Addr: addr, Reason: systemReason(err), Err: err}
 	}
 
 	return &ResolvedAddr{
@@ -30,37 +106,139 @@ func ResolveAddr(addr string) (*ResolvedAddr, error) {
 	}, nil
 }
 
-func stripCharacters(str, chrs string) string {
-	return strings.Map(func(r rune) rune {
-		if strings.IndexRune(chrs, r) < 0 {
-			return r
+// systemReason classifies an error returned by the system resolver
+func systemReason(err error) string {
+	if dnsErr, ok := err.(*net.DNSError); ok {
+		switch {
+		case dnsErr.IsNotFound:
+			return ReasonNXDomain
+		case dnsErr.IsTimeout:
+			return ReasonTimeout
+		case dnsErr.IsTemporary:
+			return ReasonServFail
 		}
-		return -1
-	}, str)
+	}
+	return ReasonError
+}
+
+// DNSResolver sends PTR, A and AAAA queries straight to Server, over UDP
+// falling back to TCP for truncated answers, or only over TCP if TCP is set.
+// Queries which time out are retried up to Retries times.
+type DNSResolver struct {
+	// Server is the host:port of the DNS server. The port defaults to 53.
+	Server  string
+	TCP     bool
+	Timeout time.Duration
+	Retries int
+}
+
+// NewDNSResolver creates a resolver querying server with the default timeout
+// and retries
+func NewDNSResolver(server string) *DNSResolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &DNSResolver{Server: server, Timeout: 2 * time.Second, Retries: 2}
+}
+
+func (r *DNSResolver) LookupAddr(addr string) (*ResolvedAddr, error) {
+	name, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, &ResolveError{Addr: addr, Server: r.Server, Reason: ReasonError, Err: err}
+	}
+
+	answer, err := r.exchange(name, dns.TypePTR)
+	if err != nil {
+		return nil, &ResolveError{Addr: addr, Server: r.Server, Reason: exchangeReason(err), Err: err}
+	}
+	if reason := rcodeReason(answer.Rcode); reason != "" {
+		return nil, &ResolveError{Addr: addr, Server: r.Server, Reason: reason, Err: fmt.Errorf("%s", dns.RcodeToString[answer.Rcode])}
+	}
+
+	resolved := &ResolvedAddr{Addr: addr}
+	for _, rr := range answer.Answer {
+		if ptr, ok := rr.(*dns.PTR); ok {
+			resolved.Names = append(resolved.Names, ptr.Ptr)
+		}
+	}
+	if len(resolved.Names) == 0 {
+		return nil, &ResolveError{Addr: addr, Server: r.Server, Reason: ReasonNoAnswer, Err: fmt.Errorf("No PTR records for %s", name)}
+	}
+	return resolved, nil
+}
+
+// exchange sends a recursive query for name, retrying it if it times out
+func (r *DNSResolver) exchange(name string, qtype uint16) (*dns.Msg, error) {
+	query := new(dns.Msg)
+	query.SetQuestion(name, qtype)
+
+	network := "udp"
+	if r.TCP {
+		network = "tcp"
+	}
+	client := &dns.Client{Net: network, Timeout: r.Timeout}
+
+	var (
+		answer *dns.Msg
+		err    error
+	)
+	for attempt := 0; attempt <= r.Retries; attempt++ {
+		answer, _, err = client.Exchange(query, r.Server)
+		if err == nil && answer.Truncated && client.Net == "udp" {
+			tcp := &dns.Client{Net: "tcp", Timeout: r.Timeout}
+			answer, _, err = tcp.Exchange(query, r.Server)
+		}
+		if err == nil || exchangeReason(err) != ReasonTimeout {
+			break
+		}
+	}
+	return answer, err
+}
+
+func exchangeReason(err error) string {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return ReasonTimeout
+	}
+	return ReasonError
+}
+
+// rcodeReason returns why a query with rcode failed, or "" if it succeeded
+func rcodeReason(rcode int) string {
+	switch rcode {
+	case dns.RcodeSuccess:
+		return ""
+	case dns.RcodeNameError:
+		return ReasonNXDomain
+	case dns.RcodeServerFailure:
+		return ReasonServFail
+	case dns.RcodeRefused:
+		return ReasonRefused
+	default:
+		return ReasonError
+	}
 }
 
-func ResolvedAddrToAsset(resolved *ResolvedAddr, ip string, iteration int) []*Asset {
+func ResolvedAddrToAsset(resolved *ResolvedAddr, ip string) []*Asset {
 	var assets []*Asset
 	if resolved != nil {
 		if len(resolved.Names) > 0 {
 			for _, name := range resolved.Names {
-				assets = append(assets, &Asset{
-					Name:      name,
-					IPAddr:    resolved.Addr,
-					ShortName: fmt.Sprintf("var%s%d", stripCharacters(name, "`~!@#$%^&*()-_=+[]{]}\t\\|'\";:,<.>/?\n `"), iteration),
-					Label:     "Unknown",
-				})
+				asset := &Asset{
+					Name:   name,
+					IPAddr: resolved.Addr,
+				}
+				if forward, ok := resolved.Forward[name]; ok {
+					asset.ForwardIPs = forward
+					asset.FCrDNS = fcrdnsStatus(resolved.Addr, forward)
+				}
+				assets = append(assets, asset)
 			}
 			return assets
 		}
 	}
 
-	strippedIP := stripCharacters(ip, ".:[]")
 	assets = append(assets, &Asset{
-		Name:      ip,
-		IPAddr:    ip,
-		ShortName: fmt.Sprintf("var%s", strippedIP),
-		Label:     "Unknown",
+		IPAddr: ip,
 	})
 
 	return assets
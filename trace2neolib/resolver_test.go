@@ -0,0 +1,230 @@
+package trace2neolib
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startDNS serves handler over UDP and TCP on the same port of 127.0.0.1,
+// returning the address of the server
+func startDNS(t *testing.T, handler dns.HandlerFunc) string {
+	var (
+		pc  net.PacketConn
+		l   net.Listener
+		err error
+	)
+	for attempt := 0; attempt < 10; attempt++ {
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if l, err = net.Listen("tcp", pc.LocalAddr().String()); err == nil {
+			break
+		}
+		pc.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, srv := range []*dns.Server{{PacketConn: pc, Handler: handler}, {Listener: l, Handler: handler}} {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { srv.Shutdown() })
+	}
+	return pc.LocalAddr().String()
+}
+
+// queryLog counts the queries a test server received for each name, by
+// network
+type queryLog struct {
+	mu      sync.Mutex
+	queries map[string][]string
+}
+
+func (q *queryLog) add(w dns.ResponseWriter, r *dns.Msg) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.queries == nil {
+		q.queries = make(map[string][]string)
+	}
+	name := r.Question[0].Name
+	q.queries[name] = append(q.queries[name], w.RemoteAddr().Network())
+	return len(q.queries[name])
+}
+
+func (q *queryLog) get(name string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queries[name]
+}
+
+func ptr(name, target string) dns.RR {
+	return rr(name + " 60 IN PTR " + target)
+}
+
+func rr(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func testResolver(t *testing.T, log *queryLog) *DNSResolver {
+	addr := startDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		attempt := log.add(w, r)
+		name := r.Question[0].Name
+		m := new(dns.Msg)
+		m.SetReply(r)
+
+		switch name {
+		case "1.0.0.10.in-addr.arpa.":
+			m.Answer = append(m.Answer, ptr(name, "host.example.com."))
+		case "2.0.0.10.in-addr.arpa.":
+			m.Rcode = dns.RcodeNameError
+		case "3.0.0.10.in-addr.arpa.":
+			m.Rcode = dns.RcodeServerFailure
+		case "4.0.0.10.in-addr.arpa.":
+			m.Rcode = dns.RcodeRefused
+		case "5.0.0.10.in-addr.arpa.":
+			// NOERROR without any PTR records
+		case "6.0.0.10.in-addr.arpa.":
+			m.Rcode = dns.RcodeNotImplemented
+		case "7.0.0.10.in-addr.arpa.":
+			return // never answered, so every attempt times out
+		case "8.0.0.10.in-addr.arpa.":
+			// the first two attempts are lost
+			if attempt <= 2 {
+				return
+			}
+			m.Answer = append(m.Answer, ptr(name, "slow.example.com."))
+		case "9.0.0.10.in-addr.arpa.":
+			// too big for UDP, so only answered in full over TCP
+			if w.RemoteAddr().Network() == "udp" {
+				m.Truncated = true
+				break
+			}
+			m.Answer = append(m.Answer, ptr(name, "big-1.example.com."), ptr(name, "big-2.example.com."))
+		case "dual.example.com.":
+			if r.Question[0].Qtype == dns.TypeA {
+				m.Answer = append(m.Answer, rr(name+" 60 IN A 10.0.0.1"))
+			} else {
+				m.Answer = append(m.Answer, rr(name+" 60 IN AAAA 2001:db8::1"))
+			}
+		case "v6.example.com.":
+			if r.Question[0].Qtype == dns.TypeAAAA {
+				m.Answer = append(m.Answer, rr(name+" 60 IN AAAA 2001:db8::2"))
+			}
+		case "gone.example.com.":
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+
+	r := NewDNSResolver(addr)
+	r.Timeout = 100 * time.Millisecond
+	return r
+}
+
+func TestDNSResolverReasons(t *testing.T) {
+	r := testResolver(t, &queryLog{})
+
+	resolved, err := r.LookupAddr("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved.Names) != 1 || resolved.Names[0] != "host.example.com." {
+		t.Errorf("Got names %v, want host.example.com.", resolved.Names)
+	}
+
+	for addr, want := range map[string]string{
+		"10.0.0.2": ReasonNXDomain,
+		"10.0.0.3": ReasonServFail,
+		"10.0.0.4": ReasonRefused,
+		"10.0.0.5": ReasonNoAnswer,
+		"10.0.0.6": ReasonError,
+		"10.0.0.7": ReasonTimeout,
+	} {
+		_, err := r.LookupAddr(addr)
+		resolveErr, ok := err.(*ResolveError)
+		if !ok {
+			t.Errorf("Got error %v for %s, want a *ResolveError", err, addr)
+			continue
+		}
+		if resolveErr.Reason != want || resolveErr.Addr != addr || resolveErr.Server != r.Server {
+			t.Errorf("Got %+v for %s, want reason %s", resolveErr, addr, want)
+		}
+		answered := want == ReasonNXDomain || want == ReasonNoAnswer
+		if Answered(err) != answered {
+			t.Errorf("Got Answered %t for %s, want %t", !answered, addr, answered)
+		}
+	}
+	if !Answered(nil) {
+		t.Errorf("Got Answered false for a successful lookup, want true")
+	}
+
+	if _, err := r.LookupAddr("not an address"); FailureReason(err) != ReasonError {
+		t.Errorf("Got %v for an invalid address, want reason %s", err, ReasonError)
+	}
+}
+
+func TestDNSResolverRetries(t *testing.T) {
+	log := &queryLog{}
+	r := testResolver(t, log)
+
+	resolved, err := r.LookupAddr("10.0.0.8")
+	if err != nil {
+		t.Fatalf("Got %v, want the third attempt to succeed", err)
+	}
+	if resolved.Names[0] != "slow.example.com." {
+		t.Errorf("Got names %v, want slow.example.com.", resolved.Names)
+	}
+	if got := log.get("8.0.0.10.in-addr.arpa."); len(got) != 3 {
+		t.Errorf("Got %d attempts, want 3", len(got))
+	}
+
+	_, err = r.LookupAddr("10.0.0.7")
+	if FailureReason(err) != ReasonTimeout {
+		t.Errorf("Got %v, want a timeout", err)
+	}
+	if got := log.get("7.0.0.10.in-addr.arpa."); len(got) != r.Retries+1 {
+		t.Errorf("Got %d attempts, want %d", len(got), r.Retries+1)
+	}
+
+	// failures other than timeouts are not retried
+	r.LookupAddr("10.0.0.3")
+	if got := log.get("3.0.0.10.in-addr.arpa."); len(got) != 1 {
+		t.Errorf("Got %d attempts after SERVFAIL, want 1", len(got))
+	}
+}
+
+func TestDNSResolverTruncated(t *testing.T) {
+	log := &queryLog{}
+	r := testResolver(t, log)
+
+	resolved, err := r.LookupAddr("10.0.0.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved.Names) != 2 {
+		t.Errorf("Got names %v, want both names from the TCP answer", resolved.Names)
+	}
+	if got := log.get("9.0.0.10.in-addr.arpa."); len(got) != 2 || got[0] != "udp" || got[1] != "tcp" {
+		t.Errorf("Got queries over %v, want udp then tcp", got)
+	}
+
+	r.TCP = true
+	if _, err = r.LookupAddr("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.get("1.0.0.10.in-addr.arpa."); len(got) != 1 || got[0] != "tcp" {
+		t.Errorf("Got queries over %v with TCP set, want only tcp", got)
+	}
+}
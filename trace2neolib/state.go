@@ -0,0 +1,84 @@
+package trace2neolib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SweepState records the addresses a sweep has finished with, so that an
+// interrupted sweep can be resumed without resolving them again
+type SweepState struct {
+	Completed AddrSet
+}
+
+type sweepStateFile struct {
+	Completed []string  `json:"completed"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+// LoadSweepState reads the state saved to fp. The error satisfies
+// os.IsNotExist if there is none.
+func LoadSweepState(fp string) (*SweepState, error) {
+	b, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var file sweepStateFile
+	if err = json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+
+	state := &SweepState{}
+	for _, s := range file.Completed {
+		target, err := ParseTarget(s)
+		if err != nil {
+			return nil, err
+		}
+		if target.Range != nil {
+			state.Completed.Add(target.Range)
+		}
+	}
+	return state, nil
+}
+
+// Complete records that the sweep has finished with addr
+func (s *SweepState) Complete(addr string) {
+	target, err := ParseTarget(addr)
+	if err == nil && target.Range != nil {
+		s.Completed.Add(target.Range)
+	}
+}
+
+// Save writes the state to fp, replacing it atomically so that an
+// interruption never leaves a partly written state behind
+func (s *SweepState) Save(fp string) error {
+	file := sweepStateFile{SavedAt: time.Now().UTC()}
+	for _, r := range s.Completed.Ranges() {
+		file.Completed = append(file.Completed, r.String())
+	}
+	b, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(fp), filepath.Base(fp)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fp)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
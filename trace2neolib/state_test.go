@@ -0,0 +1,84 @@
+package trace2neolib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSweepState(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "assets.state.json")
+	if _, err := LoadSweepState(fp); !os.IsNotExist(err) {
+		t.Fatalf("Got %v loading a missing state, want a not exist error", err)
+	}
+
+	state := &SweepState{}
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.4", "10.0.0.3", "2001:db8::1", "not an address"} {
+		state.Complete(addr)
+	}
+	if err := state.Save(fp); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(fp + ".tmp*")
+	if len(matches) > 0 {
+		t.Errorf("Save left %v behind", matches)
+	}
+
+	loaded, err := LoadSweepState(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1-10.0.0.4", "2001:db8::1"}
+	if got := rangeStrings(loaded.Completed.Ranges()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got completed %v, want %v", got, want)
+	}
+
+	// resuming skips the completed addresses
+	targets := &AddrSet{}
+	targets.Add(mustRange(t, "10.0.0.0/29"))
+	targets.Add(mustRange(t, "2001:db8::/126"))
+	for _, r := range loaded.Completed.Ranges() {
+		targets.Remove(r)
+	}
+	want = []string{"10.0.0.5-10.0.0.6", "2001:db8::", "2001:db8::2-2001:db8::3"}
+	if got := rangeStrings(targets.Ranges()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got targets %v to resume, want %v", got, want)
+	}
+}
+
+func TestLoadSweepStateErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"truncated": `{"completed": ["10.0.0.1"`,
+		"bad range": `{"completed": ["10.0.0.9-10.0.0.1"]}`,
+	} {
+		fp := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(fp, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSweepState(fp); err == nil {
+			t.Errorf("Got nil error loading a %s state, want one", name)
+		}
+	}
+}
+
+// TestSweepStateCompleteFragmented completes every other address of a large
+// block, as a sweep whose lookups alternately time out would, which must not
+// take time quadratic in the number of ranges
+func TestSweepStateCompleteFragmented(t *testing.T) {
+	state := &SweepState{}
+	start := time.Now()
+	for i := 0; i < 1<<16; i += 2 {
+		state.Complete(fmt.Sprintf("10.0.%d.%d", i>>8, i&0xff))
+	}
+	if n := len(state.Completed.Ranges()); n != 1<<15 {
+		t.Errorf("Got %d ranges, want %d", n, 1<<15)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Completing %d addresses took %s", 1<<15, elapsed)
+	}
+}
@@ -0,0 +1,160 @@
+package trace2neolib
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kkirsche/trace2neo/traceroute"
+)
+
+// SweepResult is the outcome of resolving one address of a sweep
+type SweepResult struct {
+	// Index is the position of Addr in the sweep
+	Index    int
+	Addr     string
+	Resolved *ResolvedAddr
+	Err      error
+	// Liveness is what probing Addr found, if the sweep probes addresses
+	Liveness *traceroute.Liveness
+	ProbeErr error
+}
+
+// Sweeper resolves many addresses concurrently while staying within a query
+// budget. Queries are spread round robin over Resolvers, each of which is sent
+// at most QPS queries per second.
+type Sweeper struct {
+	// Concurrency is the number of queries in flight at once, at least 1
+	Concurrency int
+	// QPS limits the queries per second sent to each resolver, 0 for no limit
+	QPS float64
+	// Resolvers are the resolvers queries are spread over, by default the
+	// system resolver
+	Resolvers []Resolver
+	// ConfirmForward looks up the names each address resolves to, so that
+	// assets can be forward-confirmed. It needs resolvers which are also
+	// ForwardResolvers.
+	ConfirmForward bool
+	// Prober, if set, probes whether each address is alive, whether or not
+	// it resolved
+	Prober *traceroute.Prober
+}
+
+type sweepJob struct {
+	index    int
+	addr     string
+	resolver int
+}
+
+// Sweep resolves every address received from addrs until it is closed,
+// calling handle with each result in the order the addresses were received.
+// handle is called from a single goroutine, as results arrive.
+func (s *Sweeper) Sweep(addrs <-chan string, handle func(SweepResult)) {
+	concurrency := s.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	resolvers := s.Resolvers
+	if len(resolvers) == 0 {
+		resolvers = []Resolver{SystemResolver{}}
+	}
+
+	limiters := make([]*rateLimiter, len(resolvers))
+	for i := range resolvers {
+		limiters[i] = newRateLimiter(s.QPS)
+	}
+
+	jobs := make(chan sweepJob)
+	results := make(chan SweepResult, concurrency)
+	// window bounds how far ahead of the oldest unhandled address the sweep
+	// may run, and so how many results are held waiting for it
+	window := make(chan struct{}, concurrency*64)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				limiters[job.resolver].wait()
+				resolved, err := resolvers[job.resolver].LookupAddr(job.addr)
+				if forward, ok := resolvers[job.resolver].(ForwardResolver); ok && err == nil && s.ConfirmForward {
+					for _, name := range resolved.Names {
+						limiters[job.resolver].wait()
+						resolved.LookupForward(forward, name)
+					}
+				}
+				result := SweepResult{Index: job.index, Addr: job.addr, Resolved: resolved, Err: err}
+				if s.Prober != nil {
+					result.Liveness, result.ProbeErr = s.Prober.Probe(net.ParseIP(job.addr))
+				}
+				results <- result
+			}
+		}()
+	}
+
+	go func() {
+		index := 0
+		for addr := range addrs {
+			window <- struct{}{}
+			jobs <- sweepJob{index: index, addr: addr, resolver: index % len(resolvers)}
+			index++
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// results arrive in any order, so hold on to them until every earlier
+	// result has been handled
+	next := 0
+	pending := make(map[int]SweepResult)
+	for result := range results {
+		pending[result.Index] = result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			handle(r)
+			<-window
+			next++
+		}
+	}
+}
+
+// rateLimiter spaces out calls to wait so they happen at most qps times a
+// second, reading the time from now and waiting with sleep
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+func newRateLimiter(qps float64) *rateLimiter {
+	r := &rateLimiter{now: time.Now, sleep: time.Sleep}
+	if qps > 0 {
+		r.interval = time.Duration(float64(time.Second) / qps)
+	}
+	return r
+}
+
+func (r *rateLimiter) wait() {
+	if r.interval == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	now := r.now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	at := r.next
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	r.sleep(at.Sub(now))
+}
@@ -0,0 +1,226 @@
+package trace2neolib
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers every lookup with a name made from the address, after
+// calling lookup with it if set, and records when each lookup was made
+type fakeResolver struct {
+	lookup func(addr string)
+
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (f *fakeResolver) LookupAddr(addr string) (*ResolvedAddr, error) {
+	f.mu.Lock()
+	f.times = append(f.times, time.Now())
+	f.mu.Unlock()
+
+	if f.lookup != nil {
+		f.lookup(addr)
+	}
+	return &ResolvedAddr{Addr: addr, Names: []string{"host-" + addr + "."}}, nil
+}
+
+func (f *fakeResolver) lookups() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.times)
+}
+
+func addrsOf(n int) []string {
+	addrs := make([]string, n)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	return addrs
+}
+
+// feed sends addrs until it runs out or stop is closed, like the assets
+// command, counting how many were taken
+func feed(addrs []string, stop <-chan struct{}, sent *int64, mu *sync.Mutex) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, addr := range addrs {
+			select {
+			case ch <- addr:
+				mu.Lock()
+				*sent++
+				mu.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func TestSweepOrdered(t *testing.T) {
+	addrs := addrsOf(200)
+	// later addresses are answered sooner, so results arrive out of order
+	delay := func(addr string) {
+		var i, j int
+		fmt.Sscanf(addr, "10.0.%d.%d", &i, &j)
+		time.Sleep(time.Duration(7-(i*256+j)%8) * 100 * time.Microsecond)
+	}
+	resolvers := []Resolver{&fakeResolver{lookup: delay}, &fakeResolver{lookup: delay}}
+	s := &Sweeper{Concurrency: 8, Resolvers: resolvers}
+
+	var mu sync.Mutex
+	var sent int64
+	var got []SweepResult
+	s.Sweep(feed(addrs, nil, &sent, &mu), func(result SweepResult) {
+		got = append(got, result)
+	})
+
+	if len(got) != len(addrs) {
+		t.Fatalf("Got %d results, want %d", len(got), len(addrs))
+	}
+	for i, result := range got {
+		if result.Index != i || result.Addr != addrs[i] {
+			t.Fatalf("Got result %d for %s at position %d, want %s", result.Index, result.Addr, i, addrs[i])
+		}
+		if result.Err != nil || result.Resolved == nil || result.Resolved.Names[0] != "host-"+addrs[i]+"." {
+			t.Errorf("Got %+v, %v for %s, want it resolved", result.Resolved, result.Err, addrs[i])
+		}
+	}
+	for i, r := range resolvers {
+		if n := r.(*fakeResolver).lookups(); n != len(addrs)/2 {
+			t.Errorf("Got %d lookups sent to resolver %d, want %d", n, i, len(addrs)/2)
+		}
+	}
+}
+
+func TestSweepWindow(t *testing.T) {
+	const concurrency = 4
+	window := concurrency * 64
+	addrs := addrsOf(4 * window)
+
+	// the first address isn't answered until released, so no result can be
+	// handled and the sweep must stop running ahead
+	release := make(chan struct{})
+	r := &fakeResolver{lookup: func(addr string) {
+		if addr == addrs[0] {
+			<-release
+		}
+	}}
+	s := &Sweeper{Concurrency: concurrency, Resolvers: []Resolver{r}}
+
+	var mu sync.Mutex
+	var sent int64
+	handled := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Sweep(feed(addrs, nil, &sent, &mu), func(result SweepResult) {
+			if result.Index != handled {
+				t.Errorf("Got result %d, want %d", result.Index, handled)
+			}
+			handled++
+		})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.lookups() < window && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := r.lookups(); n != window {
+		t.Errorf("Got %d lookups while the first was unanswered, want the window of %d", n, window)
+	}
+	mu.Lock()
+	if sent > int64(window)+1 {
+		t.Errorf("Got %d addresses taken while the first was unanswered, want at most %d", sent, window+1)
+	}
+	mu.Unlock()
+
+	close(release)
+	<-done
+	if handled != len(addrs) {
+		t.Errorf("Got %d results handled, want %d", handled, len(addrs))
+	}
+}
+
+func TestSweepQPS(t *testing.T) {
+	const qps = 200
+	interval := time.Second / qps
+	addrs := addrsOf(20)
+	resolvers := []Resolver{&fakeResolver{}, &fakeResolver{}}
+	s := &Sweeper{Concurrency: 8, QPS: qps, Resolvers: resolvers}
+
+	var mu sync.Mutex
+	var sent int64
+	start := time.Now()
+	s.Sweep(feed(addrs, nil, &sent, &mu), func(SweepResult) {})
+
+	for i, resolver := range resolvers {
+		times := resolver.(*fakeResolver).times
+		sort.Slice(times, func(a, b int) bool { return times[a].Before(times[b]) })
+		// each lookup waits for a slot of its own, so the kth lookup to a
+		// resolver can't be made before k-1 intervals have passed
+		for k, at := range times {
+			if min := start.Add(time.Duration(k) * interval); at.Before(min) {
+				t.Errorf("Got lookup %d to resolver %d after %s, want at least %s",
+					k+1, i, at.Sub(start), min.Sub(start))
+			}
+		}
+	}
+}
+
+func TestSweepHalt(t *testing.T) {
+	addrs := addrsOf(10000)
+	s := &Sweeper{Concurrency: 2, Resolvers: []Resolver{&fakeResolver{}}}
+
+	stop := make(chan struct{})
+	var mu sync.Mutex
+	var sent int64
+	handled := 0
+	s.Sweep(feed(addrs, stop, &sent, &mu), func(result SweepResult) {
+		handled++
+		if handled == 5 {
+			close(stop)
+		}
+	})
+
+	// the sweep finishes the addresses already taken once halted
+	mu.Lock()
+	defer mu.Unlock()
+	if int64(handled) != sent {
+		t.Errorf("Got %d results handled, want the %d addresses taken", handled, sent)
+	}
+	if sent > 5+2*64+1 {
+		t.Errorf("Got %d addresses taken after halting at 5, want no more than the window", sent)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	var slept []time.Duration
+	r := newRateLimiter(10)
+	r.now = func() time.Time { return clock }
+	r.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	// three callers at once wait for successive slots
+	r.wait()
+	r.wait()
+	r.wait()
+	// by the time the next caller comes, the slots have passed
+	clock = clock.Add(time.Second)
+	r.wait()
+
+	want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 0}
+	if fmt.Sprint(slept) != fmt.Sprint(want) {
+		t.Errorf("Got sleeps %v, want %v", slept, want)
+	}
+
+	unlimited := newRateLimiter(0)
+	unlimited.sleep = func(d time.Duration) { t.Errorf("Slept %s without a limit", d) }
+	unlimited.wait()
+}
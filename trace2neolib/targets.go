@@ -0,0 +1,259 @@
+package trace2neolib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Target is a CIDR block, a range such as 10.0.0.1-10.0.0.50, a single address
+// or a hostname
+type Target struct {
+	// Range holds the addresses of the target, nil for a hostname
+	Range *AddrRange
+	// Prefix is the CIDR block of the target, if it is one
+	Prefix *net.IPNet
+	// Host is the hostname of the target, whose addresses are looked up when
+	// they are needed
+	Host string
+}
+
+// ParseTarget parses a CIDR block, range, address or hostname
+func ParseTarget(s string) (Target, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, prefix, err := net.ParseCIDR(s)
+		if err != nil {
+			return Target{}, fmt.Errorf("Failed to parse %s as CIDR block: %s", s, err)
+		}
+		return Target{Range: PrefixRange(prefix), Prefix: prefix}, nil
+	}
+	if i := strings.Index(s, "-"); i > 0 && net.ParseIP(strings.TrimSpace(s[:i])) != nil {
+		last := net.ParseIP(strings.TrimSpace(s[i+1:]))
+		if last == nil {
+			return Target{}, fmt.Errorf("Failed to parse the end of range %s", s)
+		}
+		r, err := NewAddrRange(net.ParseIP(strings.TrimSpace(s[:i])), last)
+		if err != nil {
+			return Target{}, err
+		}
+		return Target{Range: r}, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		r, _ := NewAddrRange(ip, ip)
+		return Target{Range: r}, nil
+	}
+	if !isHostname(s) {
+		return Target{}, fmt.Errorf("%s is not a CIDR block, range, address or hostname", s)
+	}
+	return Target{Host: strings.TrimSuffix(strings.ToLower(s), ".")}, nil
+}
+
+// Ranges returns the addresses of the target. A CIDR block gives the
+// addresses PrefixRange does, or every address in it if whole is set, and a
+// hostname the addresses lookup returns for it.
+func (t Target) Ranges(whole bool, lookup func(host string) ([]string, error)) ([]*AddrRange, error) {
+	if t.Host == "" {
+		if whole && t.Prefix != nil {
+			first, last := prefixBounds(t.Prefix)
+			return []*AddrRange{{First: first, Last: last}}, nil
+		}
+		return []*AddrRange{t.Range}, nil
+	}
+
+	addrs, err := lookup(t.Host)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []*AddrRange
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		r, _ := NewAddrRange(ip, ip)
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func (t Target) String() string {
+	switch {
+	case t.Host != "":
+		return t.Host
+	case t.Prefix != nil:
+		return t.Prefix.String()
+	default:
+		return t.Range.String()
+	}
+}
+
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c == '-', c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SubnetOf returns the most specific of subnets which contains ip, or nil if
+// none does
+func SubnetOf(subnets []*net.IPNet, ip net.IP) *net.IPNet {
+	var best *net.IPNet
+	bestOnes := -1
+	for _, subnet := range subnets {
+		if ones, _ := subnet.Mask.Size(); ones > bestOnes && subnet.Contains(ip) {
+			best, bestOnes = subnet, ones
+		}
+	}
+	return best
+}
+
+// ReadTargets reads targets separated by commas, whitespace or newlines.
+// Everything on a line after a # is a comment.
+func ReadTargets(r io.Reader) ([]string, error) {
+	var targets []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		targets = append(targets, SplitTargets(line)...)
+	}
+	return targets, scanner.Err()
+}
+
+// SplitTargets splits s into targets separated by commas or whitespace
+func SplitTargets(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// AddrSet is a set of addresses, held as sorted ranges which neither overlap
+// nor touch, so every address is in it at most once
+type AddrSet struct {
+	ranges []*AddrRange
+}
+
+// Add adds the addresses of r to the set, merging it with the ranges it
+// overlaps or touches
+func (s *AddrSet) Add(r *AddrRange) {
+	// i is the first range which might overlap or touch r. Sweeps complete
+	// addresses in order, so it is almost always the last range or past it,
+	// which is checked before searching.
+	n := len(s.ranges)
+	i := n
+	if n > 0 && !endsBefore(s.ranges[n-1], r.First) {
+		i = sort.Search(n, func(i int) bool { return !endsBefore(s.ranges[i], r.First) })
+	}
+
+	merged := &AddrRange{First: r.First, Last: r.Last}
+	j := i
+	for ; j < n && !startsAfter(s.ranges[j], r.Last); j++ {
+		if compareIP(s.ranges[j].First, merged.First) < 0 {
+			merged.First = s.ranges[j].First
+		}
+		if compareIP(s.ranges[j].Last, merged.Last) > 0 {
+			merged.Last = s.ranges[j].Last
+		}
+	}
+
+	// replace the ranges i to j with merged, moving only those after it
+	if i == j {
+		s.ranges = append(s.ranges, nil)
+		copy(s.ranges[i+1:], s.ranges[i:])
+	} else {
+		s.ranges = append(s.ranges[:i+1], s.ranges[j:]...)
+	}
+	s.ranges[i] = merged
+}
+
+// endsBefore reports whether every address of r comes before ip, without
+// touching it
+func endsBefore(r *AddrRange, ip net.IP) bool {
+	return compareIP(r.Last, ip) < 0 && !nextIP(r.Last).Equal(ip)
+}
+
+// startsAfter reports whether every address of r comes after ip, without
+// touching it
+func startsAfter(r *AddrRange, ip net.IP) bool {
+	return compareIP(r.First, ip) > 0 && !nextIP(ip).Equal(r.First)
+}
+
+// compareIP orders addresses, IPv4 before IPv6
+func compareIP(a, b net.IP) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return bytes.Compare(a, b)
+}
+
+// Remove removes the addresses of r from the set
+func (s *AddrSet) Remove(r *AddrRange) {
+	var kept []*AddrRange
+	for _, k := range s.ranges {
+		if len(k.First) != len(r.First) || bytes.Compare(k.Last, r.First) < 0 || bytes.Compare(k.First, r.Last) > 0 {
+			kept = append(kept, k)
+			continue
+		}
+		if bytes.Compare(k.First, r.First) < 0 {
+			kept = append(kept, &AddrRange{First: k.First, Last: prevIP(r.First)})
+		}
+		if bytes.Compare(k.Last, r.Last) > 0 {
+			kept = append(kept, &AddrRange{First: nextIP(r.Last), Last: k.Last})
+		}
+	}
+	s.ranges = kept
+}
+
+// Ranges returns the ranges of the set in order
+func (s *AddrSet) Ranges() []*AddrRange {
+	return s.ranges
+}
+
+// Len returns the number of addresses in the set, or math.MaxUint64 if there
+// are more than that
+func (s *AddrSet) Len() uint64 {
+	var n uint64
+	for _, r := range s.ranges {
+		l := r.Len()
+		if n+l < n {
+			return math.MaxUint64
+		}
+		n += l
+	}
+	return n
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	incIP(next)
+	return next
+}
+
+func prevIP(ip net.IP) net.IP {
+	prev := make(net.IP, len(ip))
+	copy(prev, ip)
+	decIP(prev)
+	return prev
+}
@@ -0,0 +1,227 @@
+package trace2neolib
+
+import (
+	"math/rand"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustRange(t *testing.T, s string) *AddrRange {
+	t.Helper()
+	target, err := ParseTarget(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target.Range
+}
+
+func rangeStrings(ranges []*AddrRange) []string {
+	var s []string
+	for _, r := range ranges {
+		s = append(s, r.String())
+	}
+	return s
+}
+
+func TestParseTarget(t *testing.T) {
+	for s, want := range map[string]string{
+		"10.0.0.0/30":              "10.0.0.1-10.0.0.2",
+		" 10.0.0.1 - 10.0.0.50 ":   "10.0.0.1-10.0.0.50",
+		"10.0.0.7":                 "10.0.0.7",
+		"::ffff:10.0.0.7":          "10.0.0.7",
+		"2001:db8::1-2001:db8::ff": "2001:db8::1-2001:db8::ff",
+		"Host.Example.COM.":        "host.example.com",
+		"under_score-1.example":    "under_score-1.example",
+	} {
+		target, err := ParseTarget(s)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %s", s, err)
+			continue
+		}
+		got := target.Host
+		if target.Range != nil {
+			got = target.Range.String()
+		}
+		if got != want {
+			t.Errorf("Got %s for %q, want %s", got, s, want)
+		}
+	}
+
+	for _, s := range []string{
+		"10.0.0.0/33",
+		"10.0.0.9-10.0.0.1",
+		"10.0.0.1-2001:db8::1",
+		"10.0.0.1-nope",
+		"not a target",
+		"bad..example.com",
+		strings.Repeat("a", 64) + ".example.com",
+		"",
+	} {
+		if _, err := ParseTarget(s); err == nil {
+			t.Errorf("Got nil error for %q, want one", s)
+		}
+	}
+}
+
+func TestTargetRanges(t *testing.T) {
+	target, err := ParseTarget("10.0.0.0/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, _ := target.Ranges(true, nil)
+	if got := rangeStrings(ranges); !reflect.DeepEqual(got, []string{"10.0.0.0-10.0.0.3"}) {
+		t.Errorf("Got %v for the whole block, want 10.0.0.0-10.0.0.3", got)
+	}
+
+	target, _ = ParseTarget("host.example.com")
+	ranges, err = target.Ranges(false, func(host string) ([]string, error) {
+		return []string{"10.0.0.1", "garbage", "2001:db8::1"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rangeStrings(ranges); !reflect.DeepEqual(got, []string{"10.0.0.1", "2001:db8::1"}) {
+		t.Errorf("Got %v for a hostname, want its addresses", got)
+	}
+}
+
+func TestSplitTargets(t *testing.T) {
+	got := SplitTargets("10.0.0.0/24,10.0.1.1 \t host.example.com,,\r\n10.0.2.1-10.0.2.9")
+	want := []string{"10.0.0.0/24", "10.0.1.1", "host.example.com", "10.0.2.1-10.0.2.9"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	got, err := ReadTargets(strings.NewReader(`# scope
+10.0.0.0/24, 10.0.1.1 # the gateway
+host.example.com
+
+  #10.9.9.9
+2001:db8::/126`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/24", "10.0.1.1", "host.example.com", "2001:db8::/126"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
+
+func TestAddrSetAdd(t *testing.T) {
+	for _, test := range []struct {
+		add  []string
+		want []string
+	}{
+		{[]string{"10.0.0.1-10.0.0.9", "10.0.0.5-10.0.0.20"}, []string{"10.0.0.1-10.0.0.20"}},
+		{[]string{"10.0.0.5-10.0.0.20", "10.0.0.1-10.0.0.9"}, []string{"10.0.0.1-10.0.0.20"}},
+		{[]string{"10.0.0.1-10.0.0.9", "10.0.0.10-10.0.0.20"}, []string{"10.0.0.1-10.0.0.20"}},
+		{[]string{"10.0.0.10-10.0.0.20", "10.0.0.1-10.0.0.9"}, []string{"10.0.0.1-10.0.0.20"}},
+		{[]string{"10.0.0.1-10.0.0.9", "10.0.0.11-10.0.0.20"}, []string{"10.0.0.1-10.0.0.9", "10.0.0.11-10.0.0.20"}},
+		{[]string{"10.0.0.1-10.0.0.20", "10.0.0.5-10.0.0.6"}, []string{"10.0.0.1-10.0.0.20"}},
+		{[]string{"10.0.0.1", "10.0.0.5", "10.0.0.3", "10.0.0.2-10.0.0.4"}, []string{"10.0.0.1-10.0.0.5"}},
+		{[]string{"10.0.0.1", "10.0.0.3", "10.0.0.5", "10.0.0.0-10.0.0.9"}, []string{"10.0.0.0-10.0.0.9"}},
+		{[]string{"10.0.0.255", "10.0.1.0"}, []string{"10.0.0.255-10.0.1.0"}},
+		{[]string{"2001:db8::1", "10.0.0.1", "10.0.0.2"}, []string{"10.0.0.1-10.0.0.2", "2001:db8::1"}},
+		{[]string{"255.255.255.255", "0.0.0.0"}, []string{"0.0.0.0", "255.255.255.255"}},
+	} {
+		set := &AddrSet{}
+		for _, s := range test.add {
+			set.Add(mustRange(t, s))
+		}
+		if got := rangeStrings(set.Ranges()); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Adding %v got %v, want %v", test.add, got, test.want)
+		}
+	}
+}
+
+func TestAddrSetAddCopies(t *testing.T) {
+	r := mustRange(t, "10.0.0.1-10.0.0.9")
+	set := &AddrSet{}
+	set.Add(r)
+	set.Add(mustRange(t, "10.0.0.10-10.0.0.20"))
+	if r.Last.String() != "10.0.0.9" {
+		t.Errorf("Add changed the range added to %s", r)
+	}
+}
+
+func TestAddrSetRemove(t *testing.T) {
+	for _, test := range []struct {
+		remove string
+		want   []string
+	}{
+		{"10.0.0.5-10.0.0.6", []string{"10.0.0.1-10.0.0.4", "10.0.0.7-10.0.0.20", "10.0.1.1-10.0.1.9"}},
+		{"10.0.0.0-10.0.0.4", []string{"10.0.0.5-10.0.0.20", "10.0.1.1-10.0.1.9"}},
+		{"10.0.0.15-10.0.1.4", []string{"10.0.0.1-10.0.0.14", "10.0.1.5-10.0.1.9"}},
+		{"10.0.0.1-10.0.0.20", []string{"10.0.1.1-10.0.1.9"}},
+		{"10.0.0.21-10.0.1.0", []string{"10.0.0.1-10.0.0.20", "10.0.1.1-10.0.1.9"}},
+		{"10.0.0.0/8", nil},
+		{"2001:db8::/32", []string{"10.0.0.1-10.0.0.20", "10.0.1.1-10.0.1.9"}},
+	} {
+		set := &AddrSet{}
+		set.Add(mustRange(t, "10.0.0.1-10.0.0.20"))
+		set.Add(mustRange(t, "10.0.1.1-10.0.1.9"))
+		target, err := ParseTarget(test.remove)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges, _ := target.Ranges(true, nil)
+		set.Remove(ranges[0])
+		if got := rangeStrings(set.Ranges()); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Removing %s got %v, want %v", test.remove, got, test.want)
+		}
+	}
+}
+
+func TestAddrSetLen(t *testing.T) {
+	set := &AddrSet{}
+	set.Add(mustRange(t, "10.0.0.1-10.0.0.20"))
+	set.Add(mustRange(t, "10.0.1.1"))
+	if n := set.Len(); n != 21 {
+		t.Errorf("Got length %d, want 21", n)
+	}
+	set.Add(&AddrRange{First: net.ParseIP("::"), Last: net.ParseIP("ffff::")})
+	if n := set.Len(); n != ^uint64(0) {
+		t.Errorf("Got length %d for a huge set, want the maximum", n)
+	}
+}
+
+// TestAddrSetAddRandom checks Add against a set of every address added, over
+// addresses close enough together that ranges often overlap and touch
+func TestAddrSetAddRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 200; round++ {
+		set := &AddrSet{}
+		var added [64]bool
+		for k := 0; k < 1+rng.Intn(20); k++ {
+			first := rng.Intn(len(added))
+			last := first + rng.Intn(4)
+			if last >= len(added) {
+				last = len(added) - 1
+			}
+			set.Add(&AddrRange{First: net.IPv4(10, 0, 0, byte(first)).To4(), Last: net.IPv4(10, 0, 0, byte(last)).To4()})
+			for a := first; a <= last; a++ {
+				added[a] = true
+			}
+		}
+
+		var want []string
+		for a := 0; a < len(added); a++ {
+			if !added[a] {
+				continue
+			}
+			b := a
+			for b+1 < len(added) && added[b+1] {
+				b++
+			}
+			want = append(want, (&AddrRange{First: net.IPv4(10, 0, 0, byte(a)).To4(), Last: net.IPv4(10, 0, 0, byte(b)).To4()}).String())
+			a = b
+		}
+		if got := rangeStrings(set.Ranges()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Got %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package trace2neolib
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// TraceFormatVersion is the version of the serialised Trace format. It is
+// bumped whenever a change would stop older readers from understanding a trace.
+const TraceFormatVersion = 1
+
+// Trace is a single traceroute from a source towards a target
+type Trace struct {
+	Version     int               `json:"version"`
+	ID          string            `json:"id"`
+	Source      net.IP            `json:"source,omitempty"`
+	Target      net.IP            `json:"target"`
+	TargetName  string            `json:"target_name,omitempty"`
+	Method      string            `json:"method"`
+	StartTime   time.Time         `json:"start_time"`
+	Hops        []Hop             `json:"hops"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Hop holds every reply received to the probes sent with a single TTL
+type Hop struct {
+	TTL     int     `json:"ttl"`
+	Replies []Reply `json:"replies"`
+}
+
+// Reply is a single probe reply, or a timeout if Timeout is set. Probe is the
+// number of the probe among those sent with the hop's TTL, counting from 1, or
+// 0 if the source doesn't say. ICMPType and ICMPCode are nil unless the source
+// recorded them, as 0 is the echo reply type. Annotations carry markers such as
+// unreachable flags (!H, !N) and MPLS labels.
+type Reply struct {
+	IP          net.IP        `json:"ip,omitempty"`
+	Name        string        `json:"name,omitempty"`
+	RTT         time.Duration `json:"rtt_ns,omitempty"`
+	Timeout     bool          `json:"timeout,omitempty"`
+	Probe       int           `json:"probe,omitempty"`
+	ICMPType    *int          `json:"icmp_type,omitempty"`
+	ICMPCode    *int          `json:"icmp_code,omitempty"`
+	Annotations []string      `json:"annotations,omitempty"`
+}
+
+// Link joins a responding address to a responding address of the hop before it
+type Link struct {
+	From        net.IP
+	To          net.IP
+	Name        string
+	TTL         int
+	RTT         time.Duration
+	Annotations []string
+	// Gap is how many TTLs apart From and To are, more than 1 where hops
+	// which timed out were bridged over
+	Gap int
+	// Ambiguous is set where To may not have been reached through From: the
+	// previous hop had several responders and none answered the same probe
+	Ambiguous bool
+}
+
+// NewTrace creates an empty trace towards target with a fresh ID
+func NewTrace(target net.IP, method string, startTime time.Time) *Trace {
+	return &Trace{
+		Version:   TraceFormatVersion,
+		ID:        NewTraceID(),
+		Target:    target,
+		Method:    method,
+		StartTime: startTime,
+	}
+}
+
+// NewTraceID returns a random identifier for a trace
+func NewTraceID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// Annotate records a key, such as the measurement a trace came from, on the
+// trace
+func (t *Trace) Annotate(key, value string) {
+	if t.Annotations == nil {
+		t.Annotations = make(map[string]string)
+	}
+	t.Annotations[key] = value
+}
+
+// Links walks the hops of the trace and links every responding address to the
+// responding addresses of the previous responding hop, starting at the source.
+// Where the previous hop had several responders, as behind a load balancer, a
+// reply is linked to the address which answered the same probe, and only if
+// there is none to all of them, marked Ambiguous. Hops which only timed out
+// are bridged over, recording the Gap. Repeated replies from an address are
+// linked once with the fastest RTT.
+func (t *Trace) Links() []Link {
+	var links []Link
+	previous := []Reply{{IP: t.Source}}
+	previousTTL := 0
+	for _, hop := range t.Hops {
+		var current []Reply
+		for _, reply := range hop.Replies {
+			if reply.Timeout || reply.IP == nil {
+				continue
+			}
+			current = append(current, reply)
+
+			from, ambiguous := linkedFrom(previous, reply)
+			for _, addr := range from {
+				links = addLink(links, Link{
+					From:        addr,
+					To:          reply.IP,
+					Name:        reply.Name,
+					TTL:         hop.TTL,
+					RTT:         reply.RTT,
+					Annotations: appendMissing(nil, reply.Annotations...),
+					Gap:         hop.TTL - previousTTL,
+					Ambiguous:   ambiguous,
+				})
+			}
+		}
+
+		if len(current) > 0 {
+			previous, previousTTL = current, hop.TTL
+		}
+	}
+
+	return links
+}
+
+// linkedFrom returns the addresses among the replies of the previous hop which
+// reply was reached through, and whether which of them it was is unknown
+func linkedFrom(previous []Reply, reply Reply) ([]net.IP, bool) {
+	addrs := distinctIPs(previous, 0)
+	if len(addrs) <= 1 {
+		return addrs, false
+	}
+	if sameProbe := distinctIPs(previous, reply.Probe); reply.Probe != 0 && len(sameProbe) == 1 {
+		return sameProbe, false
+	}
+	return addrs, true
+}
+
+// distinctIPs returns the addresses of replies to probe, or of every reply if
+// probe is 0
+func distinctIPs(replies []Reply, probe int) []net.IP {
+	var addrs []net.IP
+	for _, reply := range replies {
+		if reply.IP == nil || (probe != 0 && reply.Probe != probe) {
+			continue
+		}
+		seen := false
+		for _, addr := range addrs {
+			seen = seen || addr.Equal(reply.IP)
+		}
+		if !seen {
+			addrs = append(addrs, reply.IP)
+		}
+	}
+	return addrs
+}
+
+// addLink adds link to links, merging it with a link between the same
+// addresses: the fastest RTT is kept, and the link is only ambiguous if every
+// reply behind it was
+func addLink(links []Link, link Link) []Link {
+	for i := range links {
+		existing := &links[i]
+		if !existing.From.Equal(link.From) || !existing.To.Equal(link.To) || existing.TTL != link.TTL {
+			continue
+		}
+		if link.RTT > 0 && (existing.RTT == 0 || link.RTT < existing.RTT) {
+			existing.RTT = link.RTT
+		}
+		existing.Annotations = appendMissing(existing.Annotations, link.Annotations...)
+		existing.Ambiguous = existing.Ambiguous && link.Ambiguous
+		if existing.Name == "" {
+			existing.Name = link.Name
+		}
+		return links
+	}
+	return append(links, link)
+}
+
+func appendMissing(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// WriteTraceJSONL writes the trace to w as a single line of JSON
+func WriteTraceJSONL(w io.Writer, t *Trace) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadTracesJSONL reads traces written by WriteTraceJSONL, one per line.
+// Traces written by a newer, incompatible version are rejected.
+func ReadTracesJSONL(r io.Reader) ([]*Trace, error) {
+	var traces []*Trace
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var t Trace
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return traces, fmt.Errorf("Failed to decode trace on line %d: %s", line, err)
+		}
+		if t.Version > TraceFormatVersion {
+			return traces, fmt.Errorf("Trace on line %d has unsupported version %d", line, t.Version)
+		}
+		traces = append(traces, &t)
+	}
+
+	return traces, scanner.Err()
+}
@@ -0,0 +1,189 @@
+package trace2neolib
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func reply(ip string, probe int, rtt time.Duration, annotations ...string) Reply {
+	return Reply{IP: net.ParseIP(ip), Probe: probe, RTT: rtt, Annotations: annotations}
+}
+
+func linkStrings(links []Link) []string {
+	var s []string
+	for _, l := range links {
+		s = append(s, fmt.Sprintf("%s->%s ttl=%d gap=%d rtt=%s ambiguous=%t %v", l.From, l.To, l.TTL, l.Gap, l.RTT, l.Ambiguous, l.Annotations))
+	}
+	return s
+}
+
+func TestLinks(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		hops []Hop
+		want []string
+	}{
+		{
+			name: "single path",
+			hops: []Hop{
+				{TTL: 1, Replies: []Reply{reply("10.0.0.1", 1, time.Millisecond)}},
+				{TTL: 2, Replies: []Reply{reply("10.0.0.2", 1, 2*time.Millisecond)}},
+			},
+			want: []string{
+				"192.0.2.1->10.0.0.1 ttl=1 gap=1 rtt=1ms ambiguous=false []",
+				"10.0.0.1->10.0.0.2 ttl=2 gap=1 rtt=2ms ambiguous=false []",
+			},
+		},
+		{
+			name: "timeouts are bridged with their gap",
+			hops: []Hop{
+				{TTL: 1, Replies: []Reply{reply("10.0.0.1", 1, time.Millisecond)}},
+				{TTL: 2, Replies: []Reply{{Timeout: true, Probe: 1}, {Timeout: true, Probe: 2}}},
+				{TTL: 3, Replies: []Reply{{Timeout: true}}},
+				{TTL: 4, Replies: []Reply{{Timeout: true, Probe: 1}, reply("10.0.0.4", 2, 4*time.Millisecond)}},
+			},
+			want: []string{
+				"192.0.2.1->10.0.0.1 ttl=1 gap=1 rtt=1ms ambiguous=false []",
+				"10.0.0.1->10.0.0.4 ttl=4 gap=3 rtt=4ms ambiguous=false []",
+			},
+		},
+		{
+			name: "duplicate replies are linked once with the fastest rtt",
+			hops: []Hop{
+				{TTL: 1, Replies: []Reply{
+					reply("10.0.0.1", 1, 3*time.Millisecond, "!H"),
+					reply("10.0.0.1", 1, time.Millisecond),
+					reply("10.0.0.1", 2, 0, "late", "!H"),
+				}},
+			},
+			want: []string{
+				"192.0.2.1->10.0.0.1 ttl=1 gap=1 rtt=1ms ambiguous=false [!H late]",
+			},
+		},
+		{
+			name: "load balanced responders are linked by probe",
+			hops: []Hop{
+				{TTL: 1, Replies: []Reply{reply("10.0.1.1", 1, 0), reply("10.0.2.1", 2, 0), reply("10.0.1.1", 3, 0)}},
+				{TTL: 2, Replies: []Reply{reply("10.0.1.2", 1, 0), reply("10.0.2.2", 2, 0), reply("10.0.1.2", 3, 0)}},
+			},
+			want: []string{
+				"192.0.2.1->10.0.1.1 ttl=1 gap=1 rtt=0s ambiguous=false []",
+				"192.0.2.1->10.0.2.1 ttl=1 gap=1 rtt=0s ambiguous=false []",
+				"10.0.1.1->10.0.1.2 ttl=2 gap=1 rtt=0s ambiguous=false []",
+				"10.0.2.1->10.0.2.2 ttl=2 gap=1 rtt=0s ambiguous=false []",
+			},
+		},
+		{
+			name: "responders without a matching probe are ambiguous",
+			hops: []Hop{
+				{TTL: 1, Replies: []Reply{reply("10.0.1.1", 1, 0), reply("10.0.2.1", 2, 0), {Timeout: true, Probe: 3}}},
+				{TTL: 2, Replies: []Reply{reply("10.0.3.2", 3, 0), reply("10.0.1.2", 0, 0)}},
+			},
+			want: []string{
+				"192.0.2.1->10.0.1.1 ttl=1 gap=1 rtt=0s ambiguous=false []",
+				"192.0.2.1->10.0.2.1 ttl=1 gap=1 rtt=0s ambiguous=false []",
+				"10.0.1.1->10.0.3.2 ttl=2 gap=1 rtt=0s ambiguous=true []",
+				"10.0.2.1->10.0.3.2 ttl=2 gap=1 rtt=0s ambiguous=true []",
+				"10.0.1.1->10.0.1.2 ttl=2 gap=1 rtt=0s ambiguous=true []",
+				"10.0.2.1->10.0.1.2 ttl=2 gap=1 rtt=0s ambiguous=true []",
+			},
+		},
+		{
+			name: "a link confirmed by one probe is not ambiguous",
+			hops: []Hop{
+				{TTL: 1, Replies: []Reply{reply("10.0.1.1", 1, 0), reply("10.0.2.1", 2, 0)}},
+				{TTL: 2, Replies: []Reply{reply("10.0.1.2", 0, 0), reply("10.0.1.2", 1, 0)}},
+			},
+			want: []string{
+				"192.0.2.1->10.0.1.1 ttl=1 gap=1 rtt=0s ambiguous=false []",
+				"192.0.2.1->10.0.2.1 ttl=1 gap=1 rtt=0s ambiguous=false []",
+				"10.0.1.1->10.0.1.2 ttl=2 gap=1 rtt=0s ambiguous=false []",
+				"10.0.2.1->10.0.1.2 ttl=2 gap=1 rtt=0s ambiguous=true []",
+			},
+		},
+	} {
+		trace := NewTrace(net.ParseIP("10.0.0.9"), "udp", time.Unix(0, 0))
+		trace.Source = net.ParseIP("192.0.2.1")
+		trace.Hops = test.hops
+		if got := linkStrings(trace.Links()); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got links\n%v\nwant\n%v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLinksWithoutSource(t *testing.T) {
+	trace := NewTrace(net.ParseIP("10.0.0.2"), "udp", time.Unix(0, 0))
+	trace.Hops = []Hop{
+		{TTL: 1, Replies: []Reply{reply("10.0.0.1", 1, 0)}},
+		{TTL: 2, Replies: []Reply{reply("10.0.0.2", 1, 0)}},
+	}
+	want := []string{"10.0.0.1->10.0.0.2 ttl=2 gap=1 rtt=0s ambiguous=false []"}
+	if got := linkStrings(trace.Links()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got links %v, want %v", got, want)
+	}
+}
+
+func TestTraceJSONLRoundTrip(t *testing.T) {
+	echoReply, unreachable, port := 0, 3, 3
+	trace := NewTrace(net.ParseIP("2001:db8::9"), "icmp", time.Unix(1500000000, 5).UTC())
+	trace.Source = net.ParseIP("2001:db8::1")
+	trace.TargetName = "target.example.com"
+	trace.Annotate("source", "scamper")
+	trace.Hops = []Hop{
+		{TTL: 1, Replies: []Reply{
+			{IP: net.ParseIP("2001:db8::2"), Name: "gw.example.com", RTT: 1500 * time.Microsecond, Probe: 1, Annotations: []string{"mpls:16"}},
+			{Timeout: true, Probe: 2},
+		}},
+		{TTL: 2, Replies: []Reply{
+			{IP: net.ParseIP("2001:db8::9"), RTT: 3 * time.Millisecond, Probe: 1, ICMPType: &echoReply, ICMPCode: &echoReply},
+			{IP: net.ParseIP("2001:db8::9"), RTT: 4 * time.Millisecond, Probe: 2, ICMPType: &unreachable, ICMPCode: &port, Annotations: []string{"!P"}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if err := WriteTraceJSONL(&buf, trace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Fatalf("Got %d lines for 2 traces", n)
+	}
+	if !strings.Contains(buf.String(), `"icmp_type":0`) {
+		t.Errorf("An echo reply's ICMP type was left out of %s", buf.String())
+	}
+	if strings.Count(buf.String(), `"icmp_type"`) != 4 {
+		t.Errorf("Replies without an ICMP type got one in %s", buf.String())
+	}
+
+	traces, err := ReadTracesJSONL(strings.NewReader("\n" + buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("Got %d traces, want 2", len(traces))
+	}
+	if !reflect.DeepEqual(traces[1], trace) {
+		t.Errorf("Got %+v back, want %+v", traces[1], trace)
+	}
+}
+
+func TestReadTracesJSONLErrors(t *testing.T) {
+	newer := fmt.Sprintf(`{"version": %d, "id": "t2", "target": "10.0.0.1"}`, TraceFormatVersion+1)
+	traces, err := ReadTracesJSONL(strings.NewReader(`{"version": 1, "id": "t1", "target": "10.0.0.1"}` + "\n" + newer + "\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Got %v for a newer trace, want an error on line 2", err)
+	}
+	if len(traces) != 1 || traces[0].ID != "t1" {
+		t.Errorf("Got %v, want the trace before the newer one", traces)
+	}
+
+	if _, err := ReadTracesJSONL(strings.NewReader(`{"version": 1, "hops": [`)); err == nil {
+		t.Errorf("Got nil error for a truncated trace, want one")
+	}
+}
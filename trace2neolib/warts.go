@@ -0,0 +1,62 @@
+package trace2neolib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/kkirsche/trace2neo/warts"
+)
+
+// WartsTrace converts a scamper traceroute into a Trace, recording the list and
+// cycle it was collected in as annotations. The trace ID is derived from the
+// traceroute itself so importing a file twice yields the same traces.
+func WartsTrace(wt *warts.Trace) *Trace {
+	t := NewTrace(wt.Dst, wt.MethodName(), wt.Start)
+	t.ID = fmt.Sprintf("scamper-%d-%d-%s-%d", wt.ListID, wt.CycleID, wt.Dst, wt.Start.UnixNano())
+	t.Source = wt.Src
+	t.Annotate("source", "scamper")
+	t.Annotate("list_id", strconv.FormatUint(uint64(wt.ListID), 10))
+	t.Annotate("cycle_id", strconv.FormatUint(uint64(wt.CycleID), 10))
+	if wt.UserID != 0 {
+		t.Annotate("user_id", strconv.FormatUint(uint64(wt.UserID), 10))
+	}
+
+	byTTL := make(map[int]*Hop)
+	for _, wartsHop := range wt.Hops {
+		ttl := int(wartsHop.ProbeTTL)
+		hop, ok := byTTL[ttl]
+		if !ok {
+			hop = &Hop{TTL: ttl}
+			byTTL[ttl] = hop
+		}
+
+		// scamper counts attempts from 0
+		reply := Reply{
+			IP:    wartsHop.Addr,
+			RTT:   wartsHop.RTT,
+			Probe: int(wartsHop.ProbeID) + 1,
+		}
+		if wartsHop.ICMP {
+			icmpType, icmpCode := int(wartsHop.ICMPType), int(wartsHop.ICMPCode)
+			reply.ICMPType, reply.ICMPCode = &icmpType, &icmpCode
+		}
+		for _, ext := range wartsHop.ICMPExts {
+			for _, label := range ext.MPLSLabels() {
+				reply.Annotations = append(reply.Annotations, fmt.Sprintf("mpls:%d", label))
+			}
+		}
+		hop.Replies = append(hop.Replies, reply)
+	}
+
+	var ttls []int
+	for ttl := range byTTL {
+		ttls = append(ttls, ttl)
+	}
+	sort.Ints(ttls)
+	for _, ttl := range ttls {
+		t.Hops = append(t.Hops, *byTTL[ttl])
+	}
+
+	return t
+}
@@ -0,0 +1,163 @@
+package trace2neolib
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneRecord is an address or alias record of a zone. Name and Value are
+// lowercase FQDNs without the trailing dot, or addresses.
+type ZoneRecord struct {
+	// Type is A, AAAA, PTR or CNAME
+	Type string
+	// Name is the owner of an A, AAAA or CNAME record, or the name a PTR
+	// record points to
+	Name string
+	// Value is the address of an A, AAAA or PTR record, or the name a CNAME
+	// record points to
+	Value string
+}
+
+// Asset returns the asset an A, AAAA or PTR record describes, or nil for a
+// CNAME record
+func (r ZoneRecord) Asset() *Asset {
+	if r.Type == "CNAME" {
+		return nil
+	}
+	return &Asset{Name: r.Name, IPAddr: r.Value}
+}
+
+// ReadZone reads the A, AAAA, PTR and CNAME records of a BIND zone file,
+// calling handle with each. Relative names are relative to origin unless the
+// file sets $ORIGIN. file is used in errors and to resolve $INCLUDE.
+func ReadZone(r io.Reader, origin, file string, handle func(ZoneRecord)) error {
+	parser := dns.NewZoneParser(r, dns.Fqdn(origin), file)
+	parser.SetIncludeAllowed(true)
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		if record, ok := zoneRecord(rr); ok {
+			handle(record)
+		}
+	}
+	return parser.Err()
+}
+
+// TransferZone transfers zone from server, calling handle with each of its A,
+// AAAA, PTR and CNAME records. With a serial of 0 the whole zone is transferred
+// with AXFR. Otherwise only the records added since serial are, with IXFR,
+// unless the server answers with the whole zone.
+func TransferZone(server, zone string, serial uint32, handle func(ZoneRecord)) error {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	query := new(dns.Msg)
+	if serial == 0 {
+		query.SetAxfr(dns.Fqdn(zone))
+	} else {
+		query.SetIxfr(dns.Fqdn(zone), serial, ".", ".")
+	}
+
+	envelopes, err := new(dns.Transfer).In(query, server)
+	if err != nil {
+		return fmt.Errorf("Failed to transfer %s from %s: %s", zone, server, err)
+	}
+
+	// An IXFR answer is the new SOA followed by differences, each made up of
+	// the old SOA, the records deleted, the new SOA and the records added.
+	// An AXFR answer, or an IXFR answer holding the whole zone, has no SOA
+	// straight after the first.
+	var (
+		soas        int
+		incremental bool
+		adding      = true
+	)
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return fmt.Errorf("Failed to transfer %s from %s: %s", zone, server, envelope.Error)
+		}
+		for _, rr := range envelope.RR {
+			if _, ok := rr.(*dns.SOA); ok {
+				soas++
+				if soas == 2 && serial != 0 {
+					incremental = true
+				}
+				if incremental {
+					adding = soas%2 == 1
+				}
+				continue
+			}
+			if record, ok := zoneRecord(rr); ok && adding {
+				handle(record)
+			}
+		}
+	}
+	return nil
+}
+
+func zoneRecord(rr dns.RR) (ZoneRecord, bool) {
+	switch rr := rr.(type) {
+	case *dns.A:
+		return ZoneRecord{Type: "A", Name: zoneName(rr.Hdr.Name), Value: rr.A.String()}, true
+	case *dns.AAAA:
+		return ZoneRecord{Type: "AAAA", Name: zoneName(rr.Hdr.Name), Value: rr.AAAA.String()}, true
+	case *dns.PTR:
+		addr := ptrAddr(rr.Hdr.Name)
+		if addr == "" {
+			return ZoneRecord{}, false
+		}
+		return ZoneRecord{Type: "PTR", Name: zoneName(rr.Ptr), Value: addr}, true
+	case *dns.CNAME:
+		return ZoneRecord{Type: "CNAME", Name: zoneName(rr.Hdr.Name), Value: zoneName(rr.Target)}, true
+	}
+	return ZoneRecord{}, false
+}
+
+func zoneName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// ptrAddr returns the address a reverse name stands for, or "" if it is not a
+// complete in-addr.arpa or ip6.arpa name
+func ptrAddr(name string) string {
+	name = zoneName(name)
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa"):
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+		if len(labels) != 4 {
+			return ""
+		}
+		for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+			labels[i], labels[j] = labels[j], labels[i]
+		}
+		ip := net.ParseIP(strings.Join(labels, ".")).To4()
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	case strings.HasSuffix(name, ".ip6.arpa"):
+		nibbles := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+		if len(nibbles) != 32 {
+			return ""
+		}
+		var hex []byte
+		for i := len(nibbles) - 1; i >= 0; i-- {
+			if len(nibbles[i]) != 1 {
+				return ""
+			}
+			hex = append(hex, nibbles[i][0])
+			if i%4 == 0 && i > 0 {
+				hex = append(hex, ':')
+			}
+		}
+		ip := net.ParseIP(string(hex))
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	}
+	return ""
+}
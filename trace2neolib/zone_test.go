@@ -0,0 +1,171 @@
+package trace2neolib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestReadZone(t *testing.T) {
+	fp := filepath.Join("testdata", "example.com.zone")
+	f, err := os.Open(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []ZoneRecord
+	if err = ReadZone(f, "", fp, func(r ZoneRecord) { got = append(got, r) }); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ZoneRecord{
+		{Type: "A", Name: "ns1.example.com", Value: "192.0.2.53"},
+		{Type: "A", Name: "www.example.com", Value: "192.0.2.10"},
+		{Type: "AAAA", Name: "www.example.com", Value: "2001:db8::10"},
+		{Type: "A", Name: "www2.example.com", Value: "192.0.2.11"},
+		{Type: "CNAME", Name: "ftp.example.com", Value: "www.example.com"},
+		{Type: "CNAME", Name: "cdn.example.com", Value: "edge.example.net"},
+		{Type: "PTR", Name: "www.example.com", Value: "192.0.2.10"},
+		{Type: "PTR", Name: "ns1.example.com", Value: "192.0.2.53"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got records %+v, want %+v", got, want)
+	}
+
+	if asset := want[6].Asset(); asset.Name != "www.example.com" || asset.IPAddr != "192.0.2.10" {
+		t.Errorf("Got asset %+v for a PTR record", asset)
+	}
+	if asset := want[4].Asset(); asset != nil {
+		t.Errorf("Got asset %+v for a CNAME record, want none", asset)
+	}
+}
+
+func TestPTRAddr(t *testing.T) {
+	for name, want := range map[string]string{
+		"10.2.0.192.in-addr.arpa.": "192.0.2.10",
+		"10.2.0.192.IN-ADDR.ARPA":  "192.0.2.10",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": "2001:db8::1",
+		"B.A.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": "2001:db8::ab",
+		// partial names, which stand for networks rather than addresses
+		"2.0.192.in-addr.arpa.":      "",
+		"0/26.2.0.192.in-addr.arpa.": "",
+		"300.2.0.192.in-addr.arpa.":  "",
+		"8.b.d.0.1.0.0.2.ip6.arpa.":  "",
+		"10.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": "",
+		"www.example.com.": "",
+	} {
+		if got := ptrAddr(name); got != want {
+			t.Errorf("ptrAddr(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func rrs(t *testing.T, records ...string) []dns.RR {
+	var out []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+// startTransfer serves zone transfers over TCP on 127.0.0.1, answering AXFR
+// with axfr and IXFR with ixfr, each split over two envelopes
+func startTransfer(t *testing.T, axfr, ixfr []dns.RR) string {
+	return startDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		answer := axfr
+		if r.Question[0].Qtype == dns.TypeIXFR {
+			answer = ixfr
+		}
+		envelopes := make(chan *dns.Envelope)
+		go func() {
+			half := len(answer) / 2
+			envelopes <- &dns.Envelope{RR: answer[:half]}
+			envelopes <- &dns.Envelope{RR: answer[half:]}
+			close(envelopes)
+		}()
+		if err := new(dns.Transfer).Out(w, r, envelopes); err != nil {
+			t.Error(err)
+		}
+		w.Hijack()
+	})
+}
+
+func transfer(t *testing.T, server string, serial uint32) []ZoneRecord {
+	var got []ZoneRecord
+	if err := TransferZone(server, "example.com", serial, func(r ZoneRecord) { got = append(got, r) }); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestTransferZone(t *testing.T) {
+	soa := func(serial string) string {
+		return "example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. " + serial + " 7200 3600 1209600 3600"
+	}
+	axfr := rrs(t,
+		soa("3"),
+		"example.com. 3600 IN NS ns1.example.com.",
+		"www.example.com. 3600 IN A 192.0.2.10",
+		"old.example.com. 3600 IN A 192.0.2.20",
+		"ftp.example.com. 3600 IN CNAME www.example.com.",
+		soa("3"),
+	)
+	// serial 1 to 2 deletes old and adds new, and 2 to 3 deletes new again
+	// and adds ftp
+	ixfr := rrs(t,
+		soa("3"),
+		soa("1"),
+		"old.example.com. 3600 IN A 192.0.2.1",
+		soa("2"),
+		"new.example.com. 3600 IN A 192.0.2.30",
+		"mail.example.com. 3600 IN AAAA 2001:db8::25",
+		soa("2"),
+		"new.example.com. 3600 IN A 192.0.2.30",
+		soa("3"),
+		"ftp.example.com. 3600 IN CNAME www.example.com.",
+		soa("3"),
+	)
+	server := startTransfer(t, axfr, ixfr)
+
+	want := []ZoneRecord{
+		{Type: "A", Name: "www.example.com", Value: "192.0.2.10"},
+		{Type: "A", Name: "old.example.com", Value: "192.0.2.20"},
+		{Type: "CNAME", Name: "ftp.example.com", Value: "www.example.com"},
+	}
+	if got := transfer(t, server, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v from AXFR, want %+v", got, want)
+	}
+
+	// only the additions of each difference are loaded
+	want = []ZoneRecord{
+		{Type: "A", Name: "new.example.com", Value: "192.0.2.30"},
+		{Type: "AAAA", Name: "mail.example.com", Value: "2001:db8::25"},
+		{Type: "CNAME", Name: "ftp.example.com", Value: "www.example.com"},
+	}
+	if got := transfer(t, server, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v from IXFR, want %+v", got, want)
+	}
+}
+
+func TestTransferZoneFallsBackToAXFR(t *testing.T) {
+	// a server which can't send differences answers IXFR with the whole zone
+	zone := rrs(t,
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 3 7200 3600 1209600 3600",
+		"www.example.com. 3600 IN A 192.0.2.10",
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 3 7200 3600 1209600 3600",
+	)
+	server := startTransfer(t, zone, zone)
+
+	want := []ZoneRecord{{Type: "A", Name: "www.example.com", Value: "192.0.2.10"}}
+	if got := transfer(t, server, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v from IXFR answered with the whole zone, want %+v", got, want)
+	}
+}
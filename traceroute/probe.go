@@ -0,0 +1,257 @@
+package traceroute
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Liveness is what probing a host learned about whether it is alive
+type Liveness struct {
+	// Alive is whether the host answered any probe, including with a TCP
+	// reset from a closed port
+	Alive bool
+	// EchoReply is whether the host answered an ICMP echo request
+	EchoReply bool
+	// Ports are the probed TCP ports which answered a SYN with a SYN-ACK
+	Ports []int
+	// ProbedAt is when the host was probed
+	ProbedAt time.Time
+}
+
+// Prober probes whether hosts are alive with ICMP echo requests and TCP SYNs
+// to Ports, sent from raw sockets like the traceroute probes. It needs root
+// or CAP_NET_RAW. Nothing is sent beyond the SYN: the kernel resets any
+// connection a SYN-ACK begins, as it does not know of it.
+type Prober struct {
+	ICMP    bool
+	Ports   []int
+	Timeout time.Duration
+}
+
+// Check opens and closes the raw sockets the probes are sent from, for IPv6 as
+// well as IPv4 if ipv6 is set, returning an error if any can't be opened
+func (p *Prober) Check(ipv6 bool) error {
+	var networks []string
+	for _, af := range []string{"ip4", "ip6"} {
+		if af == "ip6" && !ipv6 {
+			continue
+		}
+		if p.ICMP {
+			networks = append(networks, icmpNetwork[af])
+		}
+		if len(p.Ports) > 0 {
+			networks = append(networks, af+":tcp")
+		}
+	}
+
+	for _, network := range networks {
+		conn, err := net.ListenPacket(network, "")
+		if err != nil {
+			return fmt.Errorf("Failed to open a raw %s socket, probing needs root or CAP_NET_RAW: %s", network, err)
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// icmpNetwork is the raw socket network ICMP is sent over for each family
+var icmpNetwork = map[string]string{"ip4": "ip4:icmp", "ip6": "ip6:ipv6-icmp"}
+
+// Probe sends every probe to dst at once and waits up to Timeout for answers
+func (p *Prober) Probe(dst net.IP) (*Liveness, error) {
+	af := "ip4"
+	if dst.To4() == nil {
+		af = "ip6"
+	} else {
+		dst = dst.To4()
+	}
+	src, err := sourceFor(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	liveness := &Liveness{ProbedAt: time.Now().UTC()}
+	deadline := liveness.ProbedAt.Add(p.Timeout)
+
+	var (
+		wg          sync.WaitGroup
+		echoErr     error
+		synErr      error
+		tcpAnswered bool
+	)
+	if p.ICMP {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			liveness.EchoReply, echoErr = echo(af, src, dst, deadline)
+		}()
+	}
+	if len(p.Ports) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			liveness.Ports, tcpAnswered, synErr = syn(af, src, dst, p.Ports, deadline)
+		}()
+	}
+	wg.Wait()
+
+	if echoErr != nil {
+		return nil, echoErr
+	}
+	if synErr != nil {
+		return nil, synErr
+	}
+	liveness.Alive = liveness.EchoReply || tcpAnswered
+	return liveness, nil
+}
+
+// sourceFor returns the local address packets to dst are routed from
+func sourceFor(dst net.IP) (net.IP, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	src := conn.LocalAddr().(*net.UDPAddr).IP
+	if v4 := src.To4(); v4 != nil {
+		return v4, nil
+	}
+	return src, nil
+}
+
+// echo sends an ICMP echo request to dst, reporting whether it was answered
+// before deadline
+func echo(af string, src, dst net.IP, deadline time.Time) (bool, error) {
+	request := icmp.Type(ipv4.ICMPTypeEcho)
+	if af == "ip6" {
+		request = ipv6.ICMPTypeEchoRequest
+	}
+
+	conn, err := icmp.ListenPacket(icmpNetwork[af], src.String())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	id, seq := rand.Intn(0xffff), rand.Intn(0xffff)
+	msg := icmp.Message{Type: request, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("trace2neo")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+	if _, err = conn.WriteTo(b, &net.IPAddr{IP: dst}); err != nil {
+		return false, err
+	}
+
+	conn.SetReadDeadline(deadline)
+	packet := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(packet)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return false, nil
+			}
+			return false, err
+		}
+		if isEchoReply(af, packet[:n], from, dst, id, seq) {
+			return true, nil
+		}
+	}
+}
+
+// isEchoReply reports whether packet, an ICMP message of family af read from
+// from, is the reply of dst to the echo request with id and seq
+func isEchoReply(af string, packet []byte, from net.Addr, dst net.IP, id, seq int) bool {
+	var (
+		proto               = 1
+		replyType icmp.Type = ipv4.ICMPTypeEchoReply
+	)
+	if af == "ip6" {
+		proto, replyType = 58, ipv6.ICMPTypeEchoReply
+	}
+
+	if addr, ok := from.(*net.IPAddr); !ok || !addr.IP.Equal(dst) {
+		return false
+	}
+	reply, err := icmp.ParseMessage(proto, packet)
+	if err != nil || reply.Type != replyType {
+		return false
+	}
+	body, ok := reply.Body.(*icmp.Echo)
+	return ok && body.ID == id && body.Seq == seq
+}
+
+// syn sends a TCP SYN to each of ports of dst, returning the ports which
+// answered with a SYN-ACK before deadline, and whether any port answered at
+// all
+func syn(af string, src, dst net.IP, ports []int, deadline time.Time) ([]int, bool, error) {
+	conn, err := net.ListenPacket(af+":tcp", src.String())
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	srcPort := 32768 + rand.Intn(28232)
+	seq := rand.Uint32()
+	waiting := make(map[int]bool)
+	for _, port := range ports {
+		header := makeTCPHeader(af, &src, &dst, srcPort, port, seq)
+		if _, err = conn.WriteTo(header, &net.IPAddr{IP: dst}); err != nil {
+			return nil, false, err
+		}
+		waiting[port] = true
+	}
+
+	// the IPv4 header is stripped from packets read from raw sockets, and
+	// IPv6 raw sockets never include it
+	var open []int
+	answered := false
+	conn.SetReadDeadline(deadline)
+	packet := make([]byte, maxTCPHeaderSize)
+	for len(waiting) > 0 {
+		n, from, err := conn.ReadFrom(packet)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				break
+			}
+			return nil, false, err
+		}
+		port, isOpen, ok := synAnswer(packet[:n], from, dst, srcPort, seq, waiting)
+		if !ok {
+			continue
+		}
+		delete(waiting, port)
+		answered = true
+		if isOpen {
+			open = append(open, port)
+		}
+	}
+	sort.Ints(open)
+	return open, answered, nil
+}
+
+// synAnswer reports whether packet, a TCP segment read from from, answers the
+// SYN sent with seq from srcPort to one of the waiting ports of dst, returning
+// the port, and whether it is open as it answered with a SYN-ACK rather than a
+// reset
+func synAnswer(packet []byte, from net.Addr, dst net.IP, srcPort int, seq uint32, waiting map[int]bool) (port int, open, ok bool) {
+	if addr, isIP := from.(*net.IPAddr); !isIP || !addr.IP.Equal(dst) || len(packet) < minTCPHeaderSize {
+		return 0, false, false
+	}
+
+	header := parseTCPHeader(packet)
+	port = int(header.Source)
+	if int(header.Destination) != srcPort || !waiting[port] || header.AckNum != seq+1 {
+		return 0, false, false
+	}
+	return port, header.Flags&(SYN|ACK) == SYN|ACK, true
+}
@@ -0,0 +1,102 @@
+package traceroute
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func echoPacket(t *testing.T, typ icmp.Type, id, seq int) []byte {
+	msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("trace2neo")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestIsEchoReply(t *testing.T) {
+	dst4, dst6 := net.ParseIP("192.0.2.1").To4(), net.ParseIP("2001:db8::1")
+	from4, from6 := &net.IPAddr{IP: dst4}, &net.IPAddr{IP: dst6}
+
+	tests := []struct {
+		name   string
+		af     string
+		packet []byte
+		from   net.Addr
+		dst    net.IP
+		want   bool
+	}{
+		{"reply", "ip4", echoPacket(t, ipv4.ICMPTypeEchoReply, 7, 9), from4, dst4, true},
+		{"IPv6 reply", "ip6", echoPacket(t, ipv6.ICMPTypeEchoReply, 7, 9), from6, dst6, true},
+		{"other ID", "ip4", echoPacket(t, ipv4.ICMPTypeEchoReply, 8, 9), from4, dst4, false},
+		{"other seq", "ip4", echoPacket(t, ipv4.ICMPTypeEchoReply, 7, 10), from4, dst4, false},
+		{"other host", "ip4", echoPacket(t, ipv4.ICMPTypeEchoReply, 7, 9), &net.IPAddr{IP: net.ParseIP("192.0.2.2")}, dst4, false},
+		{"own request", "ip4", echoPacket(t, ipv4.ICMPTypeEcho, 7, 9), from4, dst4, false},
+		{"IPv4 reply type over IPv6", "ip6", echoPacket(t, ipv4.ICMPTypeEchoReply, 7, 9), from6, dst6, false},
+		{"unreachable", "ip4", []byte{3, 1, 0, 0, 0, 0, 0, 0}, from4, dst4, false},
+		{"truncated", "ip4", []byte{0, 0}, from4, dst4, false},
+		{"not an IP address", "ip4", echoPacket(t, ipv4.ICMPTypeEchoReply, 7, 9), &net.UDPAddr{IP: dst4}, dst4, false},
+	}
+	for _, test := range tests {
+		if got := isEchoReply(test.af, test.packet, test.from, test.dst, 7, 9); got != test.want {
+			t.Errorf("Got %t for %s, want %t", got, test.name, test.want)
+		}
+	}
+}
+
+func tcpPacket(srcPort, dstPort int, ack uint32, flags uint8) []byte {
+	header := TCPHeader{
+		Source:      uint16(srcPort),
+		Destination: uint16(dstPort),
+		SeqNum:      1000,
+		AckNum:      ack,
+		DataOffset:  5,
+		Flags:       flags,
+		Window:      0xffff,
+	}
+	return header.Serialize()
+}
+
+func TestSYNAnswer(t *testing.T) {
+	const (
+		srcPort = 40000
+		seq     = 0xfffffff0
+	)
+	dst := net.ParseIP("192.0.2.1").To4()
+	from := &net.IPAddr{IP: dst}
+	waiting := map[int]bool{22: true, 80: true, 443: true}
+
+	tests := []struct {
+		name     string
+		packet   []byte
+		from     net.Addr
+		port     int
+		open, ok bool
+	}{
+		{"SYN-ACK", tcpPacket(443, srcPort, seq+1, SYN|ACK), from, 443, true, true},
+		{"reset", tcpPacket(22, srcPort, seq+1, RST|ACK), from, 22, false, true},
+		{"other host", tcpPacket(443, srcPort, seq+1, SYN|ACK), &net.IPAddr{IP: net.ParseIP("192.0.2.2")}, 0, false, false},
+		{"other source port", tcpPacket(443, srcPort+1, seq+1, SYN|ACK), from, 0, false, false},
+		{"other seq", tcpPacket(443, srcPort, seq, SYN|ACK), from, 0, false, false},
+		{"port not probed", tcpPacket(8080, srcPort, seq+1, SYN|ACK), from, 0, false, false},
+		{"truncated", tcpPacket(443, srcPort, seq+1, SYN|ACK)[:minTCPHeaderSize-1], from, 0, false, false},
+		{"not an IP address", tcpPacket(443, srcPort, seq+1, SYN|ACK), &net.UDPAddr{IP: dst}, 0, false, false},
+	}
+	for _, test := range tests {
+		port, open, ok := synAnswer(test.packet, test.from, dst, srcPort, seq, waiting)
+		if port != test.port || open != test.open || ok != test.ok {
+			t.Errorf("Got port %d, open %t, ok %t for %s, want %d, %t, %t",
+				port, open, ok, test.name, test.port, test.open, test.ok)
+		}
+	}
+
+	dst6 := net.ParseIP("2001:db8::1")
+	port, open, ok := synAnswer(tcpPacket(80, srcPort, seq+1, SYN|ACK), &net.IPAddr{IP: dst6}, dst6, srcPort, seq, waiting)
+	if port != 80 || !open || !ok {
+		t.Errorf("Got port %d, open %t, ok %t for an IPv6 SYN-ACK, want 80, true, true", port, open, ok)
+	}
+}
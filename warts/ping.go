@@ -0,0 +1,156 @@
+package warts
+
+import (
+	"net"
+	"time"
+)
+
+// Ping is a scamper ping measurement
+type Ping struct {
+	ListID     uint32
+	CycleID    uint32
+	UserID     uint32
+	Start      time.Time
+	StopReason uint8
+	StopData   uint8
+	ProbeCount uint16
+	ProbeSize  uint16
+	ProbeTTL   uint8
+	Method     uint8
+	SrcPort    uint16
+	DstPort    uint16
+	Sent       uint16
+	Src        net.IP
+	Dst        net.IP
+	Replies    []PingReply
+}
+
+// PingReply is a single reply received to a ping probe
+type PingReply struct {
+	Addr      net.IP
+	ProbeID   uint16
+	Flags     uint8
+	ReplyTTL  uint8
+	ReplySize uint16
+	ICMPType  uint8
+	ICMPCode  uint8
+	RTT       time.Duration
+}
+
+func (d *decoder) ping() (*Ping, error) {
+	p := &Ping{}
+	set, end := d.params()
+	if set(1) {
+		p.ListID = d.u32()
+	}
+	if set(2) {
+		p.CycleID = d.u32()
+	}
+	if set(3) || set(4) {
+		d.deprecatedAddr()
+	}
+	if set(5) {
+		p.Start = d.timeval()
+	}
+	if set(6) {
+		p.StopReason = d.u8()
+	}
+	if set(7) {
+		p.StopData = d.u8()
+	}
+	patternLen := 0
+	if set(8) {
+		patternLen = int(d.u16())
+	}
+	if set(9) {
+		d.bytes(patternLen)
+	}
+	if set(10) {
+		p.ProbeCount = d.u16()
+	}
+	if set(11) {
+		p.ProbeSize = d.u16()
+	}
+	if set(12) {
+		d.u8() // wait between probes
+	}
+	if set(13) {
+		p.ProbeTTL = d.u8()
+	}
+	if set(14) {
+		d.u16() // replies required to stop
+	}
+	if set(15) {
+		p.Sent = d.u16()
+	}
+	if set(16) {
+		p.Method = d.u8()
+	}
+	if set(17) {
+		p.SrcPort = d.u16()
+	}
+	if set(18) {
+		p.DstPort = d.u16()
+	}
+	if set(19) {
+		p.UserID = d.u32()
+	}
+	if set(20) {
+		p.Src = d.addr()
+	}
+	if set(21) {
+		p.Dst = d.addr()
+	}
+	d.skipTo(end)
+
+	replyCount := int(d.u16())
+	for i := 0; i < replyCount && d.err == nil; i++ {
+		p.Replies = append(p.Replies, d.pingReply())
+	}
+
+	return p, d.err
+}
+
+func (d *decoder) pingReply() PingReply {
+	var r PingReply
+	set, end := d.params()
+	if set(1) {
+		d.deprecatedAddr()
+	}
+	if set(2) {
+		r.Flags = d.u8()
+	}
+	if set(3) {
+		r.ReplyTTL = d.u8()
+	}
+	if set(4) {
+		r.ReplySize = d.u16()
+	}
+	if set(5) {
+		r.ICMPType = d.u8()
+		r.ICMPCode = d.u8()
+	}
+	if set(6) {
+		r.RTT = d.rtt()
+	}
+	if set(7) {
+		r.ProbeID = d.u16()
+	}
+	// reply IP ID, probe IP ID
+	for flag := 8; flag <= 9; flag++ {
+		if set(flag) {
+			d.u16()
+		}
+	}
+	// reply protocol, TCP flags
+	for flag := 10; flag <= 11; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(12) {
+		r.Addr = d.addr()
+	}
+	d.skipTo(end)
+	return r
+}
@@ -0,0 +1,227 @@
+package warts
+
+import (
+	"net"
+	"time"
+)
+
+// Probe methods used by scamper traceroutes
+const (
+	TraceMethodICMPEcho      uint8 = 0x01
+	TraceMethodUDP           uint8 = 0x02
+	TraceMethodTCP           uint8 = 0x03
+	TraceMethodICMPEchoParis uint8 = 0x04
+	TraceMethodUDPParis      uint8 = 0x05
+	TraceMethodTCPAck        uint8 = 0x06
+)
+
+// Trace is a scamper traceroute
+type Trace struct {
+	ListID     uint32
+	CycleID    uint32
+	UserID     uint32
+	Start      time.Time
+	StopReason uint8
+	StopData   uint8
+	Flags      uint8
+	Attempts   uint8
+	HopLimit   uint8
+	Method     uint8
+	ProbeSize  uint16
+	SrcPort    uint16
+	DstPort    uint16
+	FirstHop   uint8
+	Src        net.IP
+	Dst        net.IP
+	Hops       []TraceHop
+}
+
+// TraceHop is a single reply received to a traceroute probe
+type TraceHop struct {
+	Addr     net.IP
+	ProbeTTL uint8
+	ReplyTTL uint8
+	Flags    uint8
+	ProbeID  uint8
+	RTT      time.Duration
+	// ICMP is whether ICMPType and ICMPCode were recorded, as they are for
+	// ICMP replies but not for TCP ones
+	ICMP      bool
+	ICMPType  uint8
+	ICMPCode  uint8
+	ProbeSize uint16
+	ReplySize uint16
+	TCPFlags  uint8
+	ICMPExts  []ICMPExt
+}
+
+// MethodName returns a readable name for the probe method of the traceroute
+func (t *Trace) MethodName() string {
+	switch t.Method {
+	case TraceMethodICMPEcho:
+		return "icmp-echo"
+	case TraceMethodUDP:
+		return "udp"
+	case TraceMethodTCP:
+		return "tcp"
+	case TraceMethodICMPEchoParis:
+		return "icmp-echo-paris"
+	case TraceMethodUDPParis:
+		return "udp-paris"
+	case TraceMethodTCPAck:
+		return "tcp-ack"
+	}
+	return "unknown"
+}
+
+func (d *decoder) trace() (*Trace, error) {
+	t := &Trace{}
+	set, end := d.params()
+	if set(1) {
+		t.ListID = d.u32()
+	}
+	if set(2) {
+		t.CycleID = d.u32()
+	}
+	if set(3) || set(4) {
+		d.deprecatedAddr()
+	}
+	if set(5) {
+		t.Start = d.timeval()
+	}
+	if set(6) {
+		t.StopReason = d.u8()
+	}
+	if set(7) {
+		t.StopData = d.u8()
+	}
+	if set(8) {
+		t.Flags = d.u8()
+	}
+	if set(9) {
+		t.Attempts = d.u8()
+	}
+	if set(10) {
+		t.HopLimit = d.u8()
+	}
+	if set(11) {
+		t.Method = d.u8()
+	}
+	if set(12) {
+		t.ProbeSize = d.u16()
+	}
+	if set(13) {
+		t.SrcPort = d.u16()
+	}
+	if set(14) {
+		t.DstPort = d.u16()
+	}
+	if set(15) {
+		t.FirstHop = d.u8()
+	}
+	// tos, wait, loops
+	for flag := 16; flag <= 18; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(19) {
+		d.u16() // hop count
+	}
+	// gap limit, gap action, loop action
+	for flag := 20; flag <= 22; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(23) {
+		d.u16() // probes per hop
+	}
+	// wait between probes, confidence
+	for flag := 24; flag <= 25; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(26) {
+		t.Src = d.addr()
+	}
+	if set(27) {
+		t.Dst = d.addr()
+	}
+	if set(28) {
+		t.UserID = d.u32()
+	}
+	d.skipTo(end)
+
+	hopCount := int(d.u16())
+	for i := 0; i < hopCount && d.err == nil; i++ {
+		t.Hops = append(t.Hops, d.traceHop())
+	}
+
+	// PMTUD, last-ditch and doubletree data follow the hops but are not used
+	return t, d.err
+}
+
+func (d *decoder) traceHop() TraceHop {
+	var h TraceHop
+	set, end := d.params()
+	if set(1) {
+		d.deprecatedAddr()
+	}
+	if set(2) {
+		h.ProbeTTL = d.u8()
+	}
+	if set(3) {
+		h.ReplyTTL = d.u8()
+	}
+	if set(4) {
+		h.Flags = d.u8()
+	}
+	if set(5) {
+		h.ProbeID = d.u8()
+	}
+	if set(6) {
+		h.RTT = d.rtt()
+	}
+	if set(7) {
+		h.ICMP = true
+		h.ICMPType = d.u8()
+		h.ICMPCode = d.u8()
+	}
+	if set(8) {
+		h.ProbeSize = d.u16()
+	}
+	if set(9) {
+		h.ReplySize = d.u16()
+	}
+	if set(10) {
+		d.u16() // reply IP ID
+	}
+	if set(11) {
+		d.u8() // reply IP TOS
+	}
+	if set(12) {
+		d.u16() // next-hop MTU
+	}
+	if set(13) {
+		d.u16() // quoted IP length
+	}
+	if set(14) {
+		d.u8() // quoted TTL
+	}
+	if set(15) {
+		h.TCPFlags = d.u8()
+	}
+	if set(16) {
+		d.u8() // quoted TOS
+	}
+	if set(17) {
+		h.ICMPExts = d.icmpExts()
+	}
+	if set(18) {
+		h.Addr = d.addr()
+	}
+	d.skipTo(end)
+	return h
+}
@@ -0,0 +1,250 @@
+package warts
+
+import (
+	"net"
+	"time"
+)
+
+// Tracelb is a scamper MDA traceroute, which enumerates the load balanced
+// paths towards a destination as a graph of nodes and links
+type Tracelb struct {
+	ListID    uint32
+	CycleID   uint32
+	UserID    uint32
+	Start     time.Time
+	SrcPort   uint16
+	DstPort   uint16
+	ProbeSize uint16
+	Method    uint8
+	FirstHop  uint8
+	Attempts  uint8
+	Src       net.IP
+	Dst       net.IP
+	Nodes     []TracelbNode
+	Links     []TracelbLink
+}
+
+// TracelbNode is an interface discovered by an MDA traceroute
+type TracelbNode struct {
+	Addr      net.IP
+	Flags     uint8
+	QuotedTTL uint8
+}
+
+// TracelbLink joins two nodes of an MDA traceroute. From and To index Nodes,
+// Hops counts the unresponsive hops between them plus one and RTT is the
+// fastest reply received from the far end of the link.
+type TracelbLink struct {
+	From int
+	To   int
+	Hops int
+	RTT  time.Duration
+}
+
+func (d *decoder) tracelb() (*Tracelb, error) {
+	t := &Tracelb{}
+	set, end := d.params()
+	if set(1) {
+		t.ListID = d.u32()
+	}
+	if set(2) {
+		t.CycleID = d.u32()
+	}
+	if set(3) || set(4) {
+		d.deprecatedAddr()
+	}
+	if set(5) {
+		t.Start = d.timeval()
+	}
+	if set(6) {
+		t.SrcPort = d.u16()
+	}
+	if set(7) {
+		t.DstPort = d.u16()
+	}
+	if set(8) {
+		t.ProbeSize = d.u16()
+	}
+	if set(9) {
+		t.Method = d.u8()
+	}
+	if set(10) {
+		t.FirstHop = d.u8()
+	}
+	// wait timeout, wait between probes
+	for flag := 11; flag <= 12; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(13) {
+		t.Attempts = d.u8()
+	}
+	// confidence, tos
+	for flag := 14; flag <= 15; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	var nodeCount, linkCount int
+	if set(16) {
+		nodeCount = int(d.u16())
+	}
+	if set(17) {
+		linkCount = int(d.u16())
+	}
+	// probes sent, maximum probes
+	for flag := 18; flag <= 19; flag++ {
+		if set(flag) {
+			d.u32()
+		}
+	}
+	if set(20) {
+		d.u8() // gap limit
+	}
+	if set(21) {
+		t.Src = d.addr()
+	}
+	if set(22) {
+		t.Dst = d.addr()
+	}
+	if set(23) {
+		t.UserID = d.u32()
+	}
+	d.skipTo(end)
+
+	for i := 0; i < nodeCount && d.err == nil; i++ {
+		t.Nodes = append(t.Nodes, d.tracelbNode())
+	}
+	for i := 0; i < linkCount && d.err == nil; i++ {
+		t.Links = append(t.Links, d.tracelbLink())
+	}
+
+	return t, d.err
+}
+
+func (d *decoder) tracelbNode() TracelbNode {
+	var n TracelbNode
+	set, end := d.params()
+	if set(1) {
+		d.deprecatedAddr()
+	}
+	if set(2) {
+		n.Flags = d.u8()
+	}
+	if set(3) {
+		d.u16() // link count, rebuilt from the links themselves
+	}
+	if set(4) {
+		n.QuotedTTL = d.u8()
+	}
+	if set(5) {
+		n.Addr = d.addr()
+	}
+	d.skipTo(end)
+	return n
+}
+
+func (d *decoder) tracelbLink() TracelbLink {
+	l := TracelbLink{To: -1}
+	set, end := d.params()
+	if set(1) {
+		l.From = int(d.u16())
+	}
+	if set(2) {
+		l.To = int(d.u16())
+	}
+	if set(3) {
+		l.Hops = int(d.u8())
+	}
+	d.skipTo(end)
+
+	// each hop of the link carries the set of probes sent across it
+	for hop := 0; hop < l.Hops && d.err == nil; hop++ {
+		probeCount := int(d.u16())
+		for i := 0; i < probeCount && d.err == nil; i++ {
+			rtt := d.tracelbProbe()
+			if hop == l.Hops-1 && rtt > 0 && (l.RTT == 0 || rtt < l.RTT) {
+				l.RTT = rtt
+			}
+		}
+	}
+	return l
+}
+
+// tracelbProbe reads a probe and its replies, returning the fastest round trip
+func (d *decoder) tracelbProbe() time.Duration {
+	var tx time.Time
+	replyCount := 0
+	set, end := d.params()
+	if set(1) {
+		tx = d.timeval()
+	}
+	if set(2) {
+		d.u16() // flow ID
+	}
+	// ttl, attempt
+	for flag := 3; flag <= 4; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(5) {
+		replyCount = int(d.u16())
+	}
+	d.skipTo(end)
+
+	var fastest time.Duration
+	for i := 0; i < replyCount && d.err == nil; i++ {
+		rx := d.tracelbReply()
+		if tx.IsZero() || rx.IsZero() {
+			continue
+		}
+		if rtt := rx.Sub(tx); rtt > 0 && (fastest == 0 || rtt < fastest) {
+			fastest = rtt
+		}
+	}
+	return fastest
+}
+
+// tracelbReply reads a reply, returning the time it was received. Replies
+// are decoded in full as they may add to the address table.
+func (d *decoder) tracelbReply() time.Time {
+	var rx time.Time
+	set, end := d.params()
+	if set(1) {
+		rx = d.timeval()
+	}
+	if set(2) {
+		d.u16() // IP ID
+	}
+	// ttl, flags
+	for flag := 3; flag <= 4; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(5) {
+		d.u16() // ICMP type and code
+	}
+	if set(6) {
+		d.u8() // TCP flags
+	}
+	if set(7) {
+		d.icmpExts()
+	}
+	// quoted TTL, quoted TOS
+	for flag := 8; flag <= 9; flag++ {
+		if set(flag) {
+			d.u8()
+		}
+	}
+	if set(10) {
+		d.deprecatedAddr()
+	}
+	if set(11) {
+		d.addr()
+	}
+	d.skipTo(end)
+	return rx
+}
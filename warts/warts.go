@@ -0,0 +1,279 @@
+// Package warts decodes the binary warts files written by scamper. Traceroute,
+// MDA traceroute (tracelb) and ping objects are decoded; every other object
+// type is skipped.
+package warts
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	magic            uint16 = 0x1205
+	objectHeaderSize int    = 8
+)
+
+// Object types decoded by the Reader. Lists, cycles and every other measurement
+// type are skipped.
+const (
+	typeAddress uint16 = 0x0005
+	typeTrace   uint16 = 0x0006
+	typePing    uint16 = 0x0007
+	typeTracelb uint16 = 0x0008
+)
+
+// Address types used by embedded addresses
+const (
+	addrTypeIPv4     uint8 = 0x01
+	addrTypeIPv6     uint8 = 0x02
+	addrTypeEthernet uint8 = 0x03
+	addrTypeFirewire uint8 = 0x04
+)
+
+// ICMPExt is a single RFC 4884 ICMP extension object
+type ICMPExt struct {
+	Class uint8
+	Type  uint8
+	Data  []byte
+}
+
+// MPLSLabels returns the labels of an MPLS label stack extension object
+// (class 1, type 1), outermost first
+func (e ICMPExt) MPLSLabels() []uint32 {
+	if e.Class != 1 || e.Type != 1 {
+		return nil
+	}
+
+	var labels []uint32
+	for i := 0; i+4 <= len(e.Data); i += 4 {
+		labels = append(labels, binary.BigEndian.Uint32(e.Data[i:i+4])>>12)
+	}
+	return labels
+}
+
+// Reader reads the traceroute, tracelb and ping objects of a warts file
+type Reader struct {
+	r io.Reader
+}
+
+// NewReader creates a Reader reading warts objects from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Next returns the next decoded object in the file, which is a *Trace,
+// *Tracelb or *Ping. io.EOF is returned once the file has been consumed.
+func (wr *Reader) Next() (interface{}, error) {
+	for {
+		header := make([]byte, objectHeaderSize)
+		if _, err := io.ReadFull(wr.r, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("Truncated warts object header")
+			}
+			return nil, err
+		}
+
+		if m := binary.BigEndian.Uint16(header[0:2]); m != magic {
+			return nil, fmt.Errorf("Invalid warts magic 0x%04x", m)
+		}
+		objType := binary.BigEndian.Uint16(header[2:4])
+		length := binary.BigEndian.Uint32(header[4:8])
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(wr.r, body); err != nil {
+			return nil, fmt.Errorf("Truncated warts object of type %d: %s", objType, err)
+		}
+
+		var (
+			obj interface{}
+			err error
+		)
+		d := &decoder{buf: body}
+		switch objType {
+		case typeTrace:
+			obj, err = d.trace()
+		case typeTracelb:
+			obj, err = d.tracelb()
+		case typePing:
+			obj, err = d.ping()
+		case typeAddress:
+			return nil, fmt.Errorf("Warts files using global address objects are not supported")
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+}
+
+// decoder reads the fields of a single warts object. The first error is sticky
+// so callers only need to check err once they are done.
+type decoder struct {
+	buf   []byte
+	off   int
+	err   error
+	addrs []net.IP
+}
+
+func (d *decoder) need(n int) bool {
+	if d.err != nil {
+		return false
+	}
+	if d.off+n > len(d.buf) {
+		d.err = fmt.Errorf("Warts object truncated at offset %d", d.off)
+		return false
+	}
+	return true
+}
+
+func (d *decoder) u8() uint8 {
+	if !d.need(1) {
+		return 0
+	}
+	v := d.buf[d.off]
+	d.off++
+	return v
+}
+
+func (d *decoder) u16() uint16 {
+	if !d.need(2) {
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.buf[d.off:])
+	d.off += 2
+	return v
+}
+
+func (d *decoder) u32() uint32 {
+	if !d.need(4) {
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.buf[d.off:])
+	d.off += 4
+	return v
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if !d.need(n) {
+		return nil
+	}
+	v := make([]byte, n)
+	copy(v, d.buf[d.off:d.off+n])
+	d.off += n
+	return v
+}
+
+func (d *decoder) timeval() time.Time {
+	sec := d.u32()
+	usec := d.u32()
+	return time.Unix(int64(sec), int64(usec)*int64(time.Microsecond)).UTC()
+}
+
+func (d *decoder) rtt() time.Duration {
+	return time.Duration(d.u32()) * time.Microsecond
+}
+
+// addr reads an embedded address. A zero length refers back to an address
+// already seen in this object by its index.
+func (d *decoder) addr() net.IP {
+	length := int(d.u8())
+	if length == 0 {
+		id := int(d.u32())
+		if d.err == nil && id >= len(d.addrs) {
+			d.err = fmt.Errorf("Warts address reference %d out of range", id)
+		}
+		if d.err != nil {
+			return nil
+		}
+		return d.addrs[id]
+	}
+
+	addrType := d.u8()
+	raw := d.bytes(length)
+	if d.err != nil {
+		return nil
+	}
+
+	var ip net.IP
+	switch addrType {
+	case addrTypeIPv4, addrTypeIPv6:
+		ip = net.IP(raw)
+	}
+	d.addrs = append(d.addrs, ip)
+	return ip
+}
+
+func (d *decoder) icmpExts() []ICMPExt {
+	end := d.off + int(d.u16())
+	var exts []ICMPExt
+	for d.err == nil && d.off < end {
+		length := int(d.u16())
+		ext := ICMPExt{
+			Class: d.u8(),
+			Type:  d.u8(),
+		}
+		ext.Data = d.bytes(length)
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+func (d *decoder) deprecatedAddr() {
+	d.u32()
+	if d.err == nil {
+		d.err = fmt.Errorf("Warts files using global address objects are not supported")
+	}
+}
+
+// params reads a flags field and the parameter length which follows it. The
+// returned function reports whether the 1-based flag is set and end is the
+// offset at which the parameters finish.
+func (d *decoder) params() (set func(int) bool, end int) {
+	var flags []byte
+	for {
+		b := d.u8()
+		if d.err != nil {
+			return func(int) bool { return false }, d.off
+		}
+		flags = append(flags, b&0x7f)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	set = func(flag int) bool {
+		i := (flag - 1) / 7
+		return i < len(flags) && flags[i]&(1<<uint((flag-1)%7)) != 0
+	}
+
+	hasParams := false
+	for _, b := range flags {
+		if b != 0 {
+			hasParams = true
+		}
+	}
+	if !hasParams {
+		return set, d.off
+	}
+
+	length := int(d.u16())
+	return set, d.off + length
+}
+
+// skipTo moves past any parameters this decoder does not know about
+func (d *decoder) skipTo(end int) {
+	if d.err != nil {
+		return
+	}
+	if end < d.off || end > len(d.buf) {
+		d.err = fmt.Errorf("Warts parameters overran at offset %d", d.off)
+		return
+	}
+	d.off = end
+}
@@ -0,0 +1,289 @@
+package warts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures in testdata are small hand encoded warts files:
+//
+//	trace.warts    a list object, which is skipped, then a UDP Paris traceroute
+//	               whose third hop refers back to the destination address
+//	ping.warts     an IPv6 ping whose replies refer back to the destination
+//	tracelb.warts  an MDA traceroute splitting over two paths
+//
+// Files captured from scamper itself go in testdata/scamper, see its README.
+func readFixture(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func readAll(r io.Reader) ([]interface{}, error) {
+	wr := NewReader(r)
+	var objs []interface{}
+	for {
+		obj, err := wr.Next()
+		if err == io.EOF {
+			return objs, nil
+		}
+		if err != nil {
+			return objs, err
+		}
+		objs = append(objs, obj)
+	}
+}
+
+func readOne(t *testing.T, name string) interface{} {
+	objs, err := readAll(bytes.NewReader(readFixture(t, name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("Got %d objects from %s, want 1", len(objs), name)
+	}
+	return objs[0]
+}
+
+func TestTrace(t *testing.T) {
+	trace, ok := readOne(t, "trace.warts").(*Trace)
+	if !ok {
+		t.Fatal("trace.warts did not decode to a *Trace")
+	}
+
+	if trace.ListID != 7 || trace.CycleID != 3 || trace.UserID != 42 {
+		t.Errorf("Got list %d, cycle %d and user %d, want 7, 3 and 42", trace.ListID, trace.CycleID, trace.UserID)
+	}
+	if want := time.Unix(1500000000, 250000000).UTC(); !trace.Start.Equal(want) {
+		t.Errorf("Got start %s, want %s", trace.Start, want)
+	}
+	if trace.MethodName() != "udp-paris" {
+		t.Errorf("Got method %s, want udp-paris", trace.MethodName())
+	}
+	if !trace.Src.Equal(net.ParseIP("10.0.0.1")) || !trace.Dst.Equal(net.ParseIP("10.0.0.9")) {
+		t.Errorf("Got %s to %s, want 10.0.0.1 to 10.0.0.9", trace.Src, trace.Dst)
+	}
+
+	want := []struct {
+		addr     string
+		ttl      uint8
+		probeID  uint8
+		rtt      time.Duration
+		icmpType uint8
+		icmpCode uint8
+	}{
+		{"10.0.0.2", 1, 0, 1500 * time.Microsecond, 11, 0},
+		{"10.0.0.3", 2, 1, 2250 * time.Microsecond, 11, 0},
+		{"10.0.0.9", 3, 0, 3 * time.Millisecond, 3, 3},
+	}
+	if len(trace.Hops) != len(want) {
+		t.Fatalf("Got %d hops, want %d", len(trace.Hops), len(want))
+	}
+	for i, w := range want {
+		hop := trace.Hops[i]
+		if !hop.Addr.Equal(net.ParseIP(w.addr)) || hop.ProbeTTL != w.ttl || hop.ProbeID != w.probeID ||
+			hop.RTT != w.rtt || !hop.ICMP || hop.ICMPType != w.icmpType || hop.ICMPCode != w.icmpCode {
+			t.Errorf("Got hop %d %+v, want %+v", i, hop, w)
+		}
+	}
+
+	exts := trace.Hops[1].ICMPExts
+	if len(exts) != 1 || !reflect.DeepEqual(exts[0].MPLSLabels(), []uint32{16000}) {
+		t.Errorf("Got ICMP extensions %+v, want MPLS label 16000", exts)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ping, ok := readOne(t, "ping.warts").(*Ping)
+	if !ok {
+		t.Fatal("ping.warts did not decode to a *Ping")
+	}
+
+	if ping.ListID != 9 || ping.CycleID != 4 || ping.UserID != 5 {
+		t.Errorf("Got list %d, cycle %d and user %d, want 9, 4 and 5", ping.ListID, ping.CycleID, ping.UserID)
+	}
+	if ping.ProbeCount != 3 || ping.Sent != 3 || ping.ProbeSize != 84 || ping.ProbeTTL != 64 {
+		t.Errorf("Got %+v, want 3 of 3 probes sent of 84 bytes with TTL 64", ping)
+	}
+	if !ping.Src.Equal(net.ParseIP("2001:db8::1")) || !ping.Dst.Equal(net.ParseIP("2001:db8::9")) {
+		t.Errorf("Got %s to %s, want 2001:db8::1 to 2001:db8::9", ping.Src, ping.Dst)
+	}
+
+	want := []PingReply{
+		{Addr: ping.Dst, ProbeID: 0, ReplyTTL: 64, ReplySize: 64, ICMPType: 129, RTT: 800 * time.Microsecond},
+		{Addr: ping.Dst, ProbeID: 2, ReplyTTL: 64, ReplySize: 64, ICMPType: 129, RTT: 1200 * time.Microsecond},
+	}
+	if !reflect.DeepEqual(ping.Replies, want) {
+		t.Errorf("Got replies %+v, want %+v", ping.Replies, want)
+	}
+}
+
+func TestTracelb(t *testing.T) {
+	trace, ok := readOne(t, "tracelb.warts").(*Tracelb)
+	if !ok {
+		t.Fatal("tracelb.warts did not decode to a *Tracelb")
+	}
+
+	if trace.ListID != 11 || trace.CycleID != 6 || trace.UserID != 77 {
+		t.Errorf("Got list %d, cycle %d and user %d, want 11, 6 and 77", trace.ListID, trace.CycleID, trace.UserID)
+	}
+	if trace.SrcPort != 33000 || trace.DstPort != 33435 || trace.Attempts != 2 {
+		t.Errorf("Got %+v, want ports 33000 to 33435 with 2 attempts", trace)
+	}
+
+	var nodes []string
+	for _, n := range trace.Nodes {
+		nodes = append(nodes, n.Addr.String())
+	}
+	if want := []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.9"}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Got nodes %v, want %v", nodes, want)
+	}
+
+	want := []TracelbLink{
+		{From: 0, To: 1, Hops: 1, RTT: 2 * time.Millisecond},
+		{From: 0, To: 2, Hops: 1, RTT: 2200 * time.Microsecond},
+		{From: 1, To: 3, Hops: 2, RTT: 4 * time.Millisecond},
+		{From: 2, To: 3, Hops: 1, RTT: 4500 * time.Microsecond},
+	}
+	if !reflect.DeepEqual(trace.Links, want) {
+		t.Errorf("Got links %+v, want %+v", trace.Links, want)
+	}
+}
+
+// boundaries returns the offsets at which each object of a warts file ends
+func boundaries(file []byte) map[int]bool {
+	ends := map[int]bool{0: true}
+	for off := 0; off+objectHeaderSize <= len(file); {
+		off += objectHeaderSize + int(binary.BigEndian.Uint32(file[off+4:]))
+		ends[off] = true
+	}
+	return ends
+}
+
+func TestTruncated(t *testing.T) {
+	for _, name := range []string{"trace.warts", "ping.warts", "tracelb.warts"} {
+		file := readFixture(t, name)
+		ends := boundaries(file)
+		for n := 0; n < len(file); n++ {
+			_, err := readAll(bytes.NewReader(file[:n]))
+			if ends[n] {
+				if err != nil {
+					t.Errorf("%s cut after a whole object at %d: %s", name, n, err)
+				}
+				continue
+			}
+			if err == nil {
+				t.Errorf("%s truncated to %d bytes decoded without an error", name, n)
+			}
+		}
+	}
+}
+
+func TestTruncatedBody(t *testing.T) {
+	// objects whose length covers the truncated body, so the decoder rather
+	// than the object reader has to notice that fields are missing
+	for _, name := range []string{"trace.warts", "ping.warts", "tracelb.warts"} {
+		file := readFixture(t, name)
+		for off := 0; off+objectHeaderSize <= len(file); {
+			length := int(binary.BigEndian.Uint32(file[off+4:]))
+			body := file[off+objectHeaderSize : off+objectHeaderSize+length]
+			objType := binary.BigEndian.Uint16(file[off+2:])
+			decoded := len(body)
+			if objType == typeTrace {
+				// the end of trace marker after the hops is not read
+				decoded -= 2
+			}
+			for n := 0; n < decoded; n++ {
+				d := &decoder{buf: body[:n]}
+				var err error
+				switch objType {
+				case typeTrace:
+					_, err = d.trace()
+				case typePing:
+					_, err = d.ping()
+				case typeTracelb:
+					_, err = d.tracelb()
+				default:
+					continue
+				}
+				if err == nil {
+					t.Errorf("%s object of type %d truncated to %d bytes decoded without an error", name, objType, n)
+				}
+			}
+			off += objectHeaderSize + length
+		}
+	}
+}
+
+func TestAddressReference(t *testing.T) {
+	d := &decoder{buf: []byte{4, 1, 10, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}}
+	if ip := d.addr(); !ip.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("Got %s, want 10.0.0.1", ip)
+	}
+	if ip := d.addr(); !ip.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Got %s for a reference to address 0, want 10.0.0.1", ip)
+	}
+	if ip := d.addr(); ip != nil || d.err == nil || !strings.Contains(d.err.Error(), "out of range") {
+		t.Errorf("Got %s and error %v for a reference to address 1 of 1, want an out of range error", ip, d.err)
+	}
+}
+
+func TestBadMagic(t *testing.T) {
+	file := readFixture(t, "ping.warts")
+	file[0] = 0x00
+	if _, err := NewReader(bytes.NewReader(file)).Next(); err == nil {
+		t.Error("Got no error for a file with bad magic")
+	}
+}
+
+func TestScamperFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "scamper", "*.warts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("No warts files captured from scamper in testdata/scamper, see its README")
+	}
+
+	found := map[string]int{}
+	for _, file := range files {
+		objs, err := readAll(bytes.NewReader(readFixture(t, filepath.Join("scamper", filepath.Base(file)))))
+		if err != nil {
+			t.Errorf("Failed to decode %s after %d objects: %s", file, len(objs), err)
+		}
+		for _, obj := range objs {
+			var dst net.IP
+			switch o := obj.(type) {
+			case *Trace:
+				found["trace"]++
+				dst = o.Dst
+			case *Tracelb:
+				found["tracelb"]++
+				dst = o.Dst
+			case *Ping:
+				found["ping"]++
+				dst = o.Dst
+			default:
+				t.Errorf("Got a %T from %s, want a *Trace, *Tracelb or *Ping", obj, file)
+			}
+			if dst == nil {
+				t.Errorf("Got a %T without a destination from %s", obj, file)
+			}
+		}
+	}
+	for _, kind := range []string{"trace", "tracelb", "ping"} {
+		if found[kind] == 0 {
+			t.Errorf("Got no %s from the scamper captures, want at least one", kind)
+		}
+	}
+}